@@ -0,0 +1,127 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gitchs/wsh/wshutils"
+)
+
+// archiveDirectory 将目录打包为 tar.gz，条目路径以目录名开头；指向目录外的符号链接会被跳过
+func archiveDirectory(dir string) ([]byte, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve directory: %v", err)
+	}
+	baseDir := filepath.Dir(absDir)
+
+	var buffer bytes.Buffer
+	gw := gzip.NewWriter(&buffer)
+	tw := tar.NewWriter(gw)
+
+	err = filepath.WalkDir(absDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(baseDir, path)
+		if err != nil {
+			return err
+		}
+
+		var link string
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if !insideDirectory(absDir, filepath.Dir(path), link) {
+				fmt.Printf("Skipping symlink '%s' -> '%s': points outside the directory\n", rel, link)
+				return nil
+			}
+		case info.Mode().IsRegular(), info.IsDir():
+		default:
+			fmt.Printf("Skipping special file '%s'\n", rel)
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			if _, err := io.Copy(tw, file); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive directory: %v", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close tar writer: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close gzip writer: %v", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// insideDirectory 判断符号链接目标（相对于链接所在目录解析）是否仍在 root 内
+func insideDirectory(root string, linkDir string, target string) bool {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(linkDir, target)
+	}
+	rel, err := filepath.Rel(root, filepath.Clean(target))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// directoryPipeline 远端解码并解包目录归档的命令，指定 --dest 时解包到该目录
+func directoryPipeline(opts transferOptions) string {
+	if opts.dest == "" {
+		return "base64 --decode |tar xzf -"
+	}
+	return "base64 --decode |tar xzf - -C " + wshutils.ShellQuote(opts.dest)
+}
+
+// transferDirectory 发送打包好的目录，远端解码后解包到当前目录或 --dest
+func transferDirectory(conn *wshutils.Connection, archive []byte, opts transferOptions) error {
+	progress := newProgressReporter()
+	transfer := opts.transfer
+	transfer.Progress = progress.Update
+	if err := conn.SendHeredoc(directoryPipeline(opts), base64.StdEncoding.EncodeToString(archive), transfer); err != nil {
+		return err
+	}
+	progress.Finish()
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gitchs/wsh/wshutils"
+)
+
+// downloadFile 从远端下载文件，远端执行 gzip -c | base64；路径按字面使用，不展开 ~ 和变量
+func downloadFile(conn *wshutils.Connection, remotePath string, localPath string) error {
+	quoted := wshutils.ShellQuote(remotePath)
+	payload, status, err := runMarkedCommand(conn, fmt.Sprintf("[ -r %s ] && gzip -c %s | base64", quoted, quoted))
+	if err != nil {
+		return err
+	}
+	if status != "0" {
+		return fmt.Errorf("remote command failed with status %s, is '%s' readable?", status, remotePath)
+	}
+
+	out, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create local file: %v", err)
+	}
+	defer out.Close()
+
+	if err := wshutils.DecodeStream(strings.NewReader(payload), out); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("File '%s' successfully downloaded to '%s'\n", remotePath, localPath)
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gitchs/wsh/wshutils"
+)
+
+// dryRunPreviewSize 预览第一个数据块时显示的最大字节数
+const dryRunPreviewSize = 48
+
+// sentMessage 记录的一条消息
+type sentMessage struct {
+	binary bool
+	data   string
+}
+
+// dryRunConnection 记录将要发送的消息而不建立连接，用于 --dry-run
+type dryRunConnection struct {
+	messages []sentMessage
+}
+
+// 确保 *dryRunConnection 实现 wshutils.ConnectionInterface
+var _ wshutils.ConnectionInterface = (*dryRunConnection)(nil)
+
+// SendJSON 记录 cmd 消息的命令内容，其他消息记录 JSON 文本
+func (d *dryRunConnection) SendJSON(v interface{}) error {
+	if msg, ok := v.(wshutils.CmdMsg); ok {
+		d.messages = append(d.messages, sentMessage{data: msg.Cmd})
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	d.messages = append(d.messages, sentMessage{data: string(data)})
+	return nil
+}
+
+// SendText 记录文本消息
+func (d *dryRunConnection) SendText(data string) error {
+	d.messages = append(d.messages, sentMessage{data: data})
+	return nil
+}
+
+// SendBinary 记录二进制消息
+func (d *dryRunConnection) SendBinary(data []byte) error {
+	d.messages = append(d.messages, sentMessage{binary: true, data: string(data)})
+	return nil
+}
+
+// ReadMessage 没有服务端，直接返回 io.EOF
+func (d *dryRunConnection) ReadMessage() (int, []byte, error) {
+	return 0, nil, io.EOF
+}
+
+// Close 没有需要关闭的连接
+func (d *dryRunConnection) Close() error {
+	return nil
+}
+
+// take 返回并清空已记录的消息
+func (d *dryRunConnection) take() []sentMessage {
+	messages := d.messages
+	d.messages = nil
+	return messages
+}
+
+// dryRunFiles 打印传输文件时将在远端执行的命令，不建立连接
+func dryRunFiles(target string, localFiles []string, opts transferOptions) error {
+	printDryRunHeader(target)
+	conn := &dryRunConnection{}
+	printRemoteDir(opts)
+
+	for _, localFile := range localFiles {
+		fileName := opts.remotePath(localFile)
+		fmt.Printf("File '%s' -> '%s':\n", localFile, fileName)
+
+		var err error
+		if opts.binary {
+			err = wshutils.SendFileBinary(conn, localFile, fileName, opts.transfer)
+		} else {
+			err = wshutils.SendFile(conn, localFile, fileName, opts.transfer)
+		}
+		if err != nil {
+			return err
+		}
+		printTransfer(conn.take(), opts.binary)
+
+		chmodCmd, err := opts.chmodCommand(localFile, fileName)
+		if err != nil {
+			return err
+		}
+		if chmodCmd != "" {
+			fmt.Printf("  mode:       %s\n", chmodCmd)
+		}
+		if opts.preserveMtime {
+			touchCmd, err := touchCommand(localFile, fileName)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("  mtime:      %s\n", touchCmd)
+		}
+		if opts.verify {
+			fmt.Printf("  verify:     %s\n", checksumCommand(fileName))
+		}
+	}
+
+	printDryRunFooter(opts.postCommands)
+	return nil
+}
+
+// dryRunDirectory 打印传输目录归档时将在远端执行的命令，不建立连接
+func dryRunDirectory(target string, localDir string, archive []byte, opts transferOptions) error {
+	printDryRunHeader(target)
+	conn := &dryRunConnection{}
+	printRemoteDir(opts)
+
+	fmt.Printf("Directory '%s' (%d byte archive):\n", localDir, len(archive))
+	if err := wshutils.SendHeredoc(conn, directoryPipeline(opts), base64.StdEncoding.EncodeToString(archive), opts.transfer); err != nil {
+		return err
+	}
+	printTransfer(conn.take(), false)
+
+	printDryRunFooter(opts.postCommands)
+	return nil
+}
+
+// printDryRunHeader 打印目标和传输前的终端设置命令
+func printDryRunHeader(target string) {
+	fmt.Printf("Dry run: no connection to '%s' will be opened\n", target)
+	fmt.Println("Setup:")
+	for _, cmd := range ttyCommands {
+		fmt.Printf("  %s\n", cmd)
+	}
+}
+
+// printRemoteDir 打印 --mkdir 创建目录的命令
+func printRemoteDir(opts transferOptions) {
+	if dir := opts.remoteDir(); dir != "" {
+		fmt.Printf("  mkdir -p %s\n", wshutils.ShellQuote(dir))
+	}
+}
+
+// printDryRunFooter 打印传输完成后执行的命令
+func printDryRunFooter(commands []string) {
+	fmt.Println("Finish:")
+	if len(commands) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, cmd := range commands {
+		fmt.Printf("  %s\n", cmd)
+	}
+}
+
+// printTransfer 打印握手命令、数据块统计和结束标记，数据块内容只预览第一块
+func printTransfer(messages []sentMessage, binary bool) {
+	if len(messages) == 0 {
+		return
+	}
+	fmt.Printf("  handshake:  %s\n", strings.TrimSuffix(messages[0].data, "\n"))
+
+	payload := messages[1:]
+	marker := ""
+	if !binary && len(payload) > 0 {
+		marker = strings.TrimSuffix(payload[len(payload)-1].data, "\n")
+		payload = payload[:len(payload)-1]
+	}
+
+	total, largest := 0, 0
+	for _, msg := range payload {
+		size := len(msg.data)
+		if !msg.binary {
+			size = len(strings.TrimSuffix(msg.data, "\n"))
+		}
+		total += size
+		largest = max(largest, size)
+	}
+	kind := "messages"
+	if binary {
+		kind = "binary frames"
+	}
+	fmt.Printf("  payload:    %d %s, %d bytes (largest %d)\n", len(payload), kind, total, largest)
+	if !binary && len(payload) > 0 {
+		preview := strings.TrimSuffix(payload[0].data, "\n")
+		if len(preview) > dryRunPreviewSize {
+			preview = preview[:dryRunPreviewSize] + "..."
+		}
+		fmt.Printf("  first:      %s\n", preview)
+	}
+	if marker != "" {
+		fmt.Printf("  eof marker: %s\n", marker)
+	}
+}
@@ -1,39 +1,121 @@
 package main
 
 import (
-	"bytes"
-	"compress/gzip"
-	"encoding/base64"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/gitchs/wsh/wshutils"
+	"github.com/sirupsen/logrus"
 )
 
 const (
-	// 每256字节发送1条消息
-	chunkSize = 256
-	// 结束标记
-	endMarker = "__EOF"
-	// 文件大小限制（32KB）
-	maxFileSize = 32 * 1024
+	// 默认的文件大小限制，可以通过 --max-size 修改
+	defaultMaxSize = "32KB"
+	// touch -t 使用的时间格式，GNU 和 BSD touch 都支持
+	touchTimeLayout = "200601021504.05"
 )
 
-func printUsage(configPath string, config *wshutils.Config) {
+// transferOptions 文件传输选项
+type transferOptions struct {
+	// preserveMtime 传输后将远端文件的修改时间设置为与本地一致
+	preserveMtime bool
+	// verify 传输后比较本地和远端文件的 SHA-256
+	verify bool
+	// preserveMode 传输后将远端文件的权限设置为与本地一致
+	preserveMode bool
+	// mode 传输后设置的远端文件权限，非 nil 时优先于 preserveMode
+	mode *os.FileMode
+	// dest 远端目标路径，为空时写入远端当前目录下的同名文件
+	dest string
+	// destIsDir dest 是目录（以 / 结尾或传输多个文件），文件写入 dest 下的同名文件
+	destIsDir bool
+	// mkdir 传输前在远端执行 mkdir -p 创建目标目录
+	mkdir bool
+	// resume 通过远端临时文件续传，跳过上次已经到达的数据块
+	resume bool
+	// binary 以二进制帧发送原始数据，跳过压缩和 base64，需要服务端支持
+	binary bool
+	// transfer 数据块大小和结束标记
+	transfer wshutils.TransferOptions
+	// postCommands --post-cmd 或 --no-post 指定的传输后命令，nil 时使用端点的 post_commands 或 defaultPostCommands
+	postCommands []string
+}
+
+// configFiles 可重复指定的 -c 参数
+type configFiles []string
+
+func (c *configFiles) String() string {
+	return strings.Join(*c, ", ")
+}
+
+func (c *configFiles) Set(value string) error {
+	*c = append(*c, value)
+	return nil
+}
+
+// postCmdFlags 可重复指定的 --post-cmd 参数
+type postCmdFlags []string
+
+func (p *postCmdFlags) String() string {
+	return strings.Join(*p, "; ")
+}
+
+func (p *postCmdFlags) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+// loadConfig 加载并合并配置文件，重复定义的端点在 stderr 上给出警告
+func loadConfig(configPaths []string) (*wshutils.Config, error) {
+	config, warnings, err := wshutils.LoadConfigs(configPaths)
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	return config, err
+}
+
+func printUsage(configPaths []string, config *wshutils.Config) {
 	fmt.Println("Usage:")
-	fmt.Println("  wcp [options] <endpoint-name> <local-file>                    - Copy file to remote endpoint")
-	fmt.Println("  wcp [options] <websocket-url> <local-file>                    - Copy file to custom WebSocket URL")
-	fmt.Println("  wcp [options] -c <config-file> <endpoint-name> <local-file>   - Use custom config file")
+	fmt.Println("  wcp [options] <endpoint-name> <local-file>...                 - Copy files to remote endpoint")
+	fmt.Println("  wcp [options] <websocket-url> <local-file>...                 - Copy files to custom WebSocket URL")
+	fmt.Println("  wcp [options] -c <config-file> <endpoint-name> <local-file>...- Use custom config file")
+	fmt.Println("  wcp [options] --download <endpoint-name> <remote-path> <local-path> - Copy file from remote endpoint")
+	fmt.Println("  wcp [options] --recursive <endpoint-name> <local-dir>         - Copy a directory tree")
+	fmt.Println("  some-command | wcp [options] --stdin <endpoint-name> <remote-path> - Write stdin to a remote file")
+	fmt.Println("  wcp encode <local-file>                                       - Print the encoded stream without connecting")
+	fmt.Println("  wcp decode <stream-file> <output-file>                        - Decode a stream like the remote side does")
 	fmt.Println("")
 	fmt.Println("Options:")
-	fmt.Println("  --force                    Force transfer files larger than 32KB")
+	fmt.Println("  --max-size <size>          Largest file, archive or stdin data to transfer, e.g. 256K or 1M (default 32KB)")
+	fmt.Println("  --force                    Transfer files of any size, ignoring --max-size")
+	fmt.Println("  --bind <ip>                Local source IP address for the outbound connection")
+	fmt.Println("  --yes                      Skip the confirmation prompt for protected endpoints")
+	fmt.Println("  --connect-timeout <dur>    Timeout for establishing the connection (default 10s)")
+	fmt.Println("  --proxy <url>              Proxy URL (http:// or socks5://), defaults to HTTP_PROXY/HTTPS_PROXY/ALL_PROXY")
+	fmt.Println("  --ca-file <file>           PEM CA bundle used to verify wss:// servers")
+	fmt.Println("  --chunk-size <n>           Bytes of encoded data per message (16-65536, default 256)")
+	fmt.Println("  --eof-marker <marker>      Heredoc end marker (default __EOF, a chunk equal to it is split across two lines)")
+	fmt.Println("  --resume                   Upload through a remote partial file and skip data that already arrived last time")
+	fmt.Println("  --dry-run                  Print the remote commands and payload summary without connecting")
+	fmt.Println("  --binary                   Send raw bytes as binary frames instead of base64 (server must forward them to stdin)")
+	fmt.Println("  --no-verify                Skip the SHA-256 comparison after each transfer")
+	fmt.Println("  --dest <path>              Remote destination path (a directory if it ends with / or several files are given)")
+	fmt.Println("  --mkdir                    Create the remote destination directory with mkdir -p first")
+	fmt.Println("  --preserve-mtime           Set the remote file's modification time to match the local file")
+	fmt.Println("  --preserve                 Set the remote file's permissions to match the local file (POSIX chmod)")
+	fmt.Println("  --mode <octal>             Set the remote file's permissions explicitly, e.g. 0755")
+	fmt.Println("  --post-cmd <cmd>           Command to run on the remote after the transfer (repeatable, replaces the default reset and echo)")
+	fmt.Println("  --no-post                  Run no command after the transfer")
+	fmt.Println("  --no-color                 Disable colored log output (also enabled by NO_COLOR)")
+	fmt.Println("  --version                  Print version information and exit")
 	fmt.Println("")
-	fmt.Printf("Config file: %s\n", configPath)
+	fmt.Printf("Config file: %s\n", strings.Join(configPaths, ", "))
 	fmt.Println("")
 	if config != nil && len(config.Endpoints) > 0 {
 		fmt.Println("Available endpoints:")
@@ -46,12 +128,34 @@ func printUsage(configPath string, config *wshutils.Config) {
 
 func main() {
 	// 定义命令行flags
-	var force = flag.Bool("force", false, "Force transfer files larger than 32KB")
-
-	var configPath string
-	var targetURL string
-	var localFile string
-	var arg string
+	var configFlag configFiles
+	flag.Var(&configFlag, "c", "Config file path (repeatable, later files override earlier endpoints with the same name)")
+	var force = flag.Bool("force", false, "Transfer files of any size, ignoring --max-size")
+	var maxSize = flag.String("max-size", defaultMaxSize, "Largest file, archive or stdin data to transfer, e.g. 256K or 1M")
+	var bindAddr = flag.String("bind", "", "Local source IP address for the outbound connection")
+	var assumeYes = flag.Bool("yes", false, "Skip the confirmation prompt for protected endpoints")
+	var connectTimeout = flag.Duration("connect-timeout", wshutils.DefaultConnectTimeout, "Timeout for establishing the connection")
+	var proxyURL = flag.String("proxy", "", "Proxy URL (http:// or socks5://)")
+	var caFile = flag.String("ca-file", "", "PEM CA bundle used to verify wss:// servers")
+	var recursive = flag.Bool("recursive", false, "Copy a directory tree as a tar archive")
+	var chunkSize = flag.Int("chunk-size", wshutils.DefaultChunkSize, "Bytes of encoded data per message (16-65536)")
+	var eofMarker = flag.String("eof-marker", wshutils.DefaultEOFMarker, "Heredoc end marker (letters, digits and underscores, at least 2; replaced with a random marker if it may appear in the encoded data)")
+	var resume = flag.Bool("resume", false, "Upload through a remote partial file and skip data that already arrived last time")
+	var dryRun = flag.Bool("dry-run", false, "Print the remote commands and payload summary without connecting")
+	var binary = flag.Bool("binary", false, "Send raw bytes as binary frames instead of base64 (server must support it)")
+	var noVerify = flag.Bool("no-verify", false, "Skip the SHA-256 comparison after each transfer")
+	var stdinMode = flag.Bool("stdin", false, "Read data from stdin and write it to <remote-path> on the endpoint")
+	var download = flag.Bool("download", false, "Download <remote-path> from the endpoint to <local-path>")
+	var preserveMtime = flag.Bool("preserve-mtime", false, "Set the remote file's modification time to match the local file")
+	var dest = flag.String("dest", "", "Remote destination path (a directory if it ends with / or several files are given)")
+	var mkdir = flag.Bool("mkdir", false, "Create the remote destination directory with mkdir -p before transferring")
+	var preserveMode = flag.Bool("preserve", false, "Set the remote file's permissions to match the local file")
+	var modeFlag = flag.String("mode", "", "Set the remote file's permissions explicitly (octal, e.g. 0755)")
+	var showVersion = flag.Bool("version", false, "Print version information and exit")
+	var noColor = flag.Bool("no-color", false, "Disable colored log output (also enabled by the NO_COLOR environment variable)")
+	var postCmdFlag postCmdFlags
+	flag.Var(&postCmdFlag, "post-cmd", "Command to run on the remote after the transfer (repeatable, replaces the default reset and echo)")
+	var noPost = flag.Bool("no-post", false, "Run no command after the transfer")
 
 	// 解析命令行参数
 	args := os.Args[1:]
@@ -60,239 +164,609 @@ func main() {
 	flag.CommandLine.Parse(args)
 	remainingArgs := flag.Args()
 
-	// 根据剩余参数的数量进行处理
+	if *showVersion {
+		fmt.Printf("wcp %s\n", wshutils.GetBuildInfo())
+		return
+	}
+
+	// NO_COLOR 设置为非空值时同样关闭颜色，见 https://no-color.org
+	if *noColor || os.Getenv("NO_COLOR") != "" {
+		logrus.SetFormatter(&logrus.TextFormatter{DisableColors: true})
+	}
+
+	opts := transferOptions{
+		preserveMtime: *preserveMtime,
+		verify:        !*noVerify,
+		binary:        *binary,
+		transfer:      wshutils.TransferOptions{ChunkSize: *chunkSize, EOFMarker: *eofMarker},
+	}
+	if err := opts.transfer.Validate(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts.preserveMode = *preserveMode
+	opts.dest = *dest
+	opts.destIsDir = strings.HasSuffix(*dest, "/") || *recursive
+	opts.mkdir = *mkdir
+	opts.resume = *resume
+	if opts.resume && (*binary || *recursive || *dryRun || *download) {
+		fmt.Println("Error: --resume cannot be combined with --binary, --recursive, --dry-run or --download")
+		os.Exit(1)
+	}
+	if *noPost && len(postCmdFlag) > 0 {
+		fmt.Println("Error: --no-post cannot be combined with --post-cmd")
+		os.Exit(1)
+	}
+	switch {
+	case *noPost:
+		opts.postCommands = []string{}
+	case len(postCmdFlag) > 0:
+		opts.postCommands = postCmdFlag
+	}
+	limit, err := parseSizeLimit(*maxSize, *force)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if opts.mkdir && opts.dest == "" && !*stdinMode {
+		fmt.Println("Error: --mkdir requires --dest")
+		os.Exit(1)
+	}
+	if *modeFlag != "" {
+		mode, err := parseMode(*modeFlag)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.mode = &mode
+	}
+
+	configPaths := wshutils.GetDefaultConfigPaths()
+	if len(configFlag) > 0 {
+		configPaths = configFlag
+	}
+
+	connOpts := wshutils.ConnectionOptions{
+		BindAddr:       *bindAddr,
+		CAFile:         *caFile,
+		ConnectTimeout: *connectTimeout,
+		Proxy:          *proxyURL,
+	}
+
+	// 标准输入模式：<endpoint-name/url> <remote-path>
+	if *stdinMode {
+		if *download || *recursive || *dryRun || *binary || *resume || *preserveMtime || *preserveMode || *dest != "" {
+			fmt.Println("Error: --stdin cannot be combined with --download, --recursive, --dry-run, --binary, --resume, --preserve, --preserve-mtime or --dest")
+			os.Exit(1)
+		}
+		if len(remainingArgs) != 2 {
+			fmt.Println("Usage: some-command | wcp --stdin <endpoint-name|websocket-url> <remote-path>")
+			os.Exit(1)
+		}
+		// 标准输入用于传输数据，不能再读取确认输入
+		if endpoint := lookupEndpoint(remainingArgs[0], configPaths); endpoint != nil && endpoint.Confirm && !*assumeYes {
+			fmt.Printf("Error: endpoint '%s' requires confirmation, which cannot be read while stdin carries data; pass --yes\n", endpoint.Name)
+			os.Exit(1)
+		}
+		remoteFile := remainingArgs[1]
+		opts.dest = remoteFile
+		opts.destIsDir = false
+
+		conn, endpoint := connectTarget(remainingArgs[0], configPaths, connOpts, *assumeYes, "Copying stdin to")
+		defer conn.Close()
+
+		if err := setupTTY(conn); err != nil {
+			log.Fatal("Failed to setup TTY:", err)
+		}
+		if err := createRemoteDir(conn, opts); err != nil {
+			log.Fatal("Failed to create remote directory:", err)
+		}
+		n, err := transferStdin(conn, os.Stdin, remoteFile, opts, limit)
+		if err != nil {
+			fmt.Printf("Error: Transfer to '%s' failed: %v\n", remoteFile, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%d bytes from stdin successfully transferred to '%s'\n", n, remoteFile)
+
+		if err := runPostCommands(conn, opts.postCommandsFor(endpoint)); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 下载模式：<endpoint-name/url> <remote-path> <local-path>
+	if *download {
+		if *dryRun || *dest != "" {
+			fmt.Println("Error: --dry-run and --dest cannot be used with --download")
+			os.Exit(1)
+		}
+		if len(remainingArgs) != 3 {
+			fmt.Println("Usage: wcp --download <endpoint-name|websocket-url> <remote-path> <local-path>")
+			os.Exit(1)
+		}
+		conn, _ := connectTarget(remainingArgs[0], configPaths, connOpts, *assumeYes, "Downloading from")
+		defer conn.Close()
+
+		if err := setupTTY(conn); err != nil {
+			log.Fatal("Failed to setup TTY:", err)
+		}
+		if err := downloadFile(conn, remainingArgs[1], remainingArgs[2]); err != nil {
+			log.Fatal("File download failed:", err)
+		}
+		return
+	}
+
+	// 目录模式：<endpoint-name/url> <local-dir>
+	if *recursive {
+		if len(remainingArgs) != 2 {
+			fmt.Println("Usage: wcp --recursive <endpoint-name|websocket-url> <local-dir>")
+			os.Exit(1)
+		}
+		localDir := remainingArgs[1]
+		if info, err := os.Stat(localDir); err != nil || !info.IsDir() {
+			log.Fatalf("Local directory '%s' does not exist or is not a directory", localDir)
+		}
+
+		archive, err := archiveDirectory(localDir)
+		if err != nil {
+			log.Fatal("Failed to archive directory:", err)
+		}
+		// 大小限制作用于打包压缩后的归档
+		if err := limit.check(fmt.Sprintf("archive of '%s'", localDir), int64(len(archive))); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if *dryRun {
+			opts.postCommands = opts.postCommandsFor(lookupEndpoint(remainingArgs[0], configPaths))
+			if err := dryRunDirectory(remainingArgs[0], localDir, archive, opts); err != nil {
+				log.Fatal("Dry run failed:", err)
+			}
+			return
+		}
+
+		conn, endpoint := connectTarget(remainingArgs[0], configPaths, connOpts, *assumeYes, "Copying to")
+		defer conn.Close()
+
+		if err := setupTTY(conn); err != nil {
+			log.Fatal("Failed to setup TTY:", err)
+		}
+		if err := createRemoteDir(conn, opts); err != nil {
+			log.Fatal("Failed to create remote directory:", err)
+		}
+		if err := transferDirectory(conn, archive, opts); err != nil {
+			log.Fatal("Directory transfer failed:", err)
+		}
+		fmt.Printf("Directory '%s' successfully transferred\n", localDir)
+
+		if err := runPostCommands(conn, opts.postCommandsFor(endpoint)); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 本地编解码子命令，不需要连接
+	if len(remainingArgs) > 0 {
+		switch remainingArgs[0] {
+		case "encode":
+			if len(remainingArgs) != 2 {
+				fmt.Println("Usage: wcp encode <local-file>")
+				os.Exit(1)
+			}
+			if err := encodeCommand(remainingArgs[1], opts.transfer.ChunkSize); err != nil {
+				log.Fatal("Encode failed:", err)
+			}
+			return
+		case "decode":
+			if len(remainingArgs) != 3 {
+				fmt.Println("Usage: wcp decode <stream-file> <output-file>")
+				os.Exit(1)
+			}
+			if err := decodeCommand(remainingArgs[1], remainingArgs[2]); err != nil {
+				log.Fatal("Decode failed:", err)
+			}
+			return
+		}
+	}
+
+	// 根据剩余参数的数量进行处理：<endpoint-name/url> <local-file>...
 	switch len(remainingArgs) {
 	case 0:
 		// 没有参数，显示帮助
-		configPath = wshutils.GetDefaultConfigPath()
-		config, _ := wshutils.LoadConfig(configPath)
-		printUsage(configPath, config)
+		config, _ := loadConfig(configPaths)
+		printUsage(configPaths, config)
 		os.Exit(1)
-	case 2:
-		// 两个参数：<endpoint-name/url> <local-file>
-		arg = remainingArgs[0]
-		localFile = remainingArgs[1]
-		configPath = wshutils.GetDefaultConfigPath()
-	case 3:
-		// 三个参数：-c <config-file> <endpoint-name> <local-file>
-		if remainingArgs[0] != "-c" {
-			fmt.Println("Error: Invalid arguments")
-			fmt.Println("Usage: wcp -c <config-file> <endpoint-name> <local-file>")
-			os.Exit(1)
-		}
-		configPath = remainingArgs[1]
-		arg = remainingArgs[2]
-		localFile = remainingArgs[2]
-	default:
+	case 1:
 		fmt.Println("Error: Invalid number of arguments")
 		fmt.Println("Usage:")
-		fmt.Println("  wcp <endpoint-name> <local-file>")
-		fmt.Println("  wcp <websocket-url> <local-file>")
-		fmt.Println("  wcp -c <config-file> <endpoint-name> <local-file>")
+		fmt.Println("  wcp <endpoint-name> <local-file>...")
+		fmt.Println("  wcp <websocket-url> <local-file>...")
+		fmt.Println("  wcp -c <config-file> <endpoint-name> <local-file>...")
+		os.Exit(1)
+	}
+	arg := remainingArgs[0]
+	localFiles := remainingArgs[1:]
+
+	// 先检查所有本地文件，有问题的文件跳过，其余照常传输
+	failed := 0
+	var readyFiles []string
+	for _, localFile := range localFiles {
+		if err := checkLocalFile(localFile, limit); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			failed++
+			continue
+		}
+		readyFiles = append(readyFiles, localFile)
+	}
+	if len(readyFiles) == 0 {
 		os.Exit(1)
 	}
+	if len(localFiles) > 1 {
+		opts.destIsDir = true
+	}
 
-	// 检查本地文件是否存在
-	fileInfo, err := os.Stat(localFile)
-	if os.IsNotExist(err) {
-		log.Fatalf("Local file '%s' does not exist", localFile)
+	if *dryRun {
+		opts.postCommands = opts.postCommandsFor(lookupEndpoint(arg, configPaths))
+		if err := dryRunFiles(arg, readyFiles, opts); err != nil {
+			log.Fatal("Dry run failed:", err)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
 	}
 
-	// 检查文件大小
-	fileSize := fileInfo.Size()
-	if fileSize > maxFileSize && !*force {
-		fmt.Printf("Error: File '%s' is %d bytes (%.2f KB), which exceeds the 32KB limit.\n",
-			localFile, fileSize, float64(fileSize)/1024)
-		fmt.Println("Use --force flag to transfer files larger than 32KB.")
-		fmt.Println("Note: wcp is designed for small file transfers.")
+	conn, endpoint := connectTarget(arg, configPaths, connOpts, *assumeYes, "Copying to")
+	defer conn.Close()
+
+	// 设置tty，禁止回显
+	if err := setupTTY(conn); err != nil {
+		log.Fatal("Failed to setup TTY:", err)
+	}
+
+	if err := createRemoteDir(conn, opts); err != nil {
+		log.Fatal("Failed to create remote directory:", err)
+	}
+
+	// 在同一连接上依次传输，单个文件失败不影响其他文件
+	for _, localFile := range readyFiles {
+		if err := transferFile(conn, localFile, opts); err != nil {
+			fmt.Printf("Error: File '%s' transfer failed: %v\n", localFile, err)
+			failed++
+			continue
+		}
+		fmt.Printf("File '%s' successfully transferred\n", localFile)
+	}
+
+	if err := runPostCommands(conn, opts.postCommandsFor(endpoint)); err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	if fileSize > maxFileSize {
-		fmt.Printf("Warning: Transferring large file '%s' (%d bytes, %.2f KB) with --force flag.\n",
-			localFile, fileSize, float64(fileSize)/1024)
+	if failed > 0 {
+		fmt.Printf("Error: %d of %d files failed to transfer\n", failed, len(localFiles))
+		os.Exit(1)
+	}
+}
+
+// sizeLimit --max-size 指定的传输大小限制，max 为 0 时（--force）不限制
+type sizeLimit struct {
+	max int64
+	// text 用户给出的写法，用于错误提示
+	text string
+}
+
+// parseSizeLimit 解析 --max-size，force 为 true 时不限制大小
+func parseSizeLimit(maxSize string, force bool) (sizeLimit, error) {
+	if force {
+		return sizeLimit{}, nil
+	}
+	max, err := wshutils.ParseSize(maxSize)
+	if err != nil || max <= 0 {
+		return sizeLimit{}, fmt.Errorf("invalid --max-size '%s', expected a positive size like 256K or 1M", maxSize)
+	}
+	return sizeLimit{max: max, text: maxSize}, nil
+}
+
+// check 检查 what 的大小 size 是否超出限制
+func (l sizeLimit) check(what string, size int64) error {
+	if l.max > 0 && size > l.max {
+		return fmt.Errorf("%s is %d bytes (%.2f KB), which exceeds the %s limit; raise --max-size or use --force (wcp is designed for small file transfers)",
+			what, size, float64(size)/1024, l.text)
+	}
+	return nil
+}
+
+// checkLocalFile 检查本地文件是否存在以及大小是否超出限制
+func checkLocalFile(localFile string, limit sizeLimit) error {
+	fileInfo, err := os.Stat(localFile)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("local file '%s' does not exist", localFile)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat local file '%s': %v", localFile, err)
+	}
+	if fileInfo.IsDir() {
+		return fmt.Errorf("'%s' is a directory", localFile)
 	}
 
+	return limit.check(fmt.Sprintf("file '%s'", localFile), fileInfo.Size())
+}
+
+// connectTarget 解析端点名称或URL并建立连接，出错时直接退出；arg 为 URL 时返回的端点为 nil
+func connectTarget(arg string, configPaths []string, connOpts wshutils.ConnectionOptions, assumeYes bool, action string) (*wshutils.Connection, *wshutils.Endpoint) {
+	var endpoint *wshutils.Endpoint
+	var targetURL string
+
 	// 检查是否是预定义的端点名称
 	if !wshutils.IsURL(arg) {
 		// 尝试从配置文件加载端点
-		config, err := wshutils.LoadConfig(configPath)
+		config, err := loadConfig(configPaths)
 		if err != nil {
 			log.Fatal("failed to load config:", err)
 		}
 
-		endpoint, err := wshutils.FindEndpoint(config, arg)
+		endpoint, err = wshutils.FindEndpoint(config, arg)
 		if err != nil {
-			fmt.Printf("Error: %v\n\n", err)
-			printUsage(configPath, config)
+			fmt.Printf("Error: %v\n", err)
+			// 有相近的端点名时只给出建议，否则显示用法和端点列表
+			if suggestion := wshutils.FormatSuggestions(wshutils.SuggestEndpoints(config, arg)); suggestion != "" {
+				fmt.Println(suggestion)
+			} else {
+				fmt.Println()
+				printUsage(configPaths, config)
+			}
+			os.Exit(1)
+		}
+		if err := endpoint.Validate(); err != nil {
+			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
 
+		if !assumeYes {
+			if err := wshutils.ConfirmEndpoint(endpoint, os.Stdin, os.Stdout); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
 		targetURL = endpoint.URL
-		fmt.Printf("Copying to endpoint '%s' (%s)...\n", endpoint.Name, endpoint.Description)
+		connOpts = endpoint.ConnectionOptions(connOpts)
+		fmt.Printf("%s endpoint '%s' (%s)...\n", action, endpoint.Name, endpoint.Description)
 	} else {
 		targetURL = arg
 	}
 
 	// 创建连接
-	conn, err := wshutils.NewConnection(targetURL)
+	conn, err := wshutils.NewConnectionWithOptions(targetURL, connOpts)
 	if err != nil {
 		log.Fatal("Failed to connect:", err)
 	}
-	defer conn.Close()
+	return conn, endpoint
+}
 
-	// 设置tty，禁止回显
-	if err := setupTTY(conn); err != nil {
-		log.Fatal("Failed to setup TTY:", err)
-	}
+// transferFile 执行文件传输
+func transferFile(conn *wshutils.Connection, localFile string, opts transferOptions) error {
+	fileName := opts.remotePath(localFile)
 
-	// 执行文件传输
-	if err := transferFile(conn, localFile); err != nil {
-		log.Fatal("File transfer failed:", err)
+	// 编码并通过 heredoc 发送，远端解码后写入文件；二进制模式直接发送原始字节
+	if opts.resume {
+		if err := resumeUpload(conn, localFile, fileName, opts); err != nil {
+			return err
+		}
+	} else {
+		progress := newProgressReporter()
+		transfer := opts.transfer
+		transfer.Progress = progress.Update
+		send := conn.SendFile
+		if opts.binary {
+			send = conn.SendFileBinary
+		}
+		if err := send(localFile, fileName, transfer); err != nil {
+			return err
+		}
+		progress.Finish()
 	}
 
-	fmt.Printf("File '%s' successfully transferred\n", localFile)
+	// 设置权限，需要远端提供 POSIX chmod
+	chmodCmd, err := opts.chmodCommand(localFile, fileName)
+	if err != nil {
+		return err
+	}
+	if chmodCmd != "" {
+		if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: chmodCmd + "\n"}); err != nil {
+			return fmt.Errorf("failed to send chmod command: %v", err)
+		}
+	}
 
-	// 等待接收响应消息
-	fmt.Println("Waiting for response...")
-	for {
-		_, msg, err := conn.ReadMessage()
+	// 同步修改时间，以UTC表示避免远端时区影响
+	if opts.preserveMtime {
+		touchCmd, err := touchCommand(localFile, fileName)
 		if err != nil {
-			fmt.Printf("Connection closed: %v\n", err)
-			break
+			return err
+		}
+		if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: touchCmd + "\n"}); err != nil {
+			return fmt.Errorf("failed to send touch command: %v", err)
+		}
+	}
+
+	// 校验远端文件，发现经过PTY传输时被破坏的数据
+	if opts.verify {
+		if err := verifyChecksum(conn, localFile, fileName); err != nil {
+			return err
 		}
-		fmt.Printf("Received: %s", string(msg))
 	}
+
+	return nil
 }
 
-// transferFile 执行文件传输
-func transferFile(conn *wshutils.Connection, localFile string) error {
-	fileName := filepath.Base(localFile)
+// defaultPostCommands 没有通过 --post-cmd、--no-post 或端点的 post_commands 指定时，所有文件传输完成后执行的命令
+var defaultPostCommands = []string{
+	"reset",           // 重置终端
+	"echo 'it works'", // 显示成功消息
+}
 
-	// 1. 发送握手消息
-	handshakeMsg := fmt.Sprintf("cat <<'__EOF' |base64 --decode |gunzip > %s\n", fileName)
-	if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: handshakeMsg}); err != nil {
-		return fmt.Errorf("failed to send handshake: %v", err)
+// postCommandsFor 传输完成后执行的命令：命令行优先，其次是端点的 post_commands，最后是 defaultPostCommands
+func (opts transferOptions) postCommandsFor(endpoint *wshutils.Endpoint) []string {
+	switch {
+	case opts.postCommands != nil:
+		return opts.postCommands
+	case endpoint != nil && endpoint.PostCommands != nil:
+		return endpoint.PostCommands
+	default:
+		return defaultPostCommands
 	}
+}
 
-	// 2. 读取文件并编码
-	encodedData, err := encodeFile(localFile)
+// lookupEndpoint --dry-run 时查找端点以显示它的 post_commands，arg 是 URL 或找不到端点时返回 nil
+func lookupEndpoint(arg string, configPaths []string) *wshutils.Endpoint {
+	if wshutils.IsURL(arg) {
+		return nil
+	}
+	config, err := loadConfig(configPaths)
 	if err != nil {
-		return fmt.Errorf("failed to encode file: %v", err)
+		return nil
 	}
-
-	// 3. 分块发送编码后的数据
-	if err := sendEncodedData(conn, encodedData); err != nil {
-		return fmt.Errorf("failed to send file data: %v", err)
+	endpoint, err := wshutils.FindEndpoint(config, arg)
+	if err != nil {
+		return nil
 	}
+	return endpoint
+}
 
-	// 4. 发送结束标记
-	if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: endMarker + "\n"}); err != nil {
-		return fmt.Errorf("failed to send end marker: %v", err)
+// touchCommand 将远端文件的修改时间设置为与本地文件一致的命令
+func touchCommand(localFile string, remoteFile string) (string, error) {
+	fileInfo, err := os.Stat(localFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat local file: %v", err)
 	}
+	return fmt.Sprintf("TZ=UTC touch -t %s %s", fileInfo.ModTime().UTC().Format(touchTimeLayout), wshutils.ShellQuote(remoteFile)), nil
+}
 
-	// 5. 传输完成后执行reset和echo
-	postCommands := []string{
-		"reset",           // 重置终端
-		"echo 'it works'", // 显示成功消息
+// remotePath 本地文件在远端的目标路径
+func (opts transferOptions) remotePath(localFile string) string {
+	fileName := filepath.Base(localFile)
+	// 以 - 开头的文件名会被 chmod、touch 等命令当作选项
+	if strings.HasPrefix(fileName, "-") {
+		fileName = "./" + fileName
 	}
-
-	for _, cmd := range postCommands {
-		if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: cmd + "\n"}); err != nil {
-			return fmt.Errorf("failed to send post command '%s': %v", cmd, err)
-		}
+	switch {
+	case opts.dest == "":
+		return fileName
+	case opts.destIsDir:
+		return path.Join(opts.dest, fileName)
+	default:
+		return opts.dest
 	}
-	go func() {
-		time.Sleep(5 * time.Second)
-		conn.Close()
-	}()
-
-	return nil
 }
 
-// encodeFile 编码文件
-func encodeFile(localFile string) (string, error) {
-	// 打开源文件
-	sourceFile, err := os.Open(localFile)
-	if err != nil {
-		return "", fmt.Errorf("failed to open source file: %v", err)
+// remoteDir 需要 mkdir -p 创建的远端目录，未指定 --mkdir 时返回空字符串
+func (opts transferOptions) remoteDir() string {
+	if !opts.mkdir {
+		return ""
 	}
-	defer sourceFile.Close()
-
-	// 创建gzip压缩buffer
-	var gzipBuffer bytes.Buffer
-	gw := gzip.NewWriter(&gzipBuffer)
+	if opts.destIsDir {
+		return opts.dest
+	}
+	return path.Dir(opts.dest)
+}
 
-	// 复制文件内容到gzip压缩器
-	if _, err := io.Copy(gw, sourceFile); err != nil {
-		return "", fmt.Errorf("failed to compress file: %v", err)
+// createRemoteDir 指定 --mkdir 时在远端创建目标目录
+func createRemoteDir(conn wshutils.ConnectionInterface, opts transferOptions) error {
+	dir := opts.remoteDir()
+	if dir == "" {
+		return nil
 	}
+	return conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: "mkdir -p " + wshutils.ShellQuote(dir) + "\n"})
+}
 
-	// 关闭gzip writer
-	if err := gw.Close(); err != nil {
-		return "", fmt.Errorf("failed to close gzip writer: %v", err)
+// chmodCommand 设置远端文件权限的命令，没有指定 --mode 或 --preserve 时返回空字符串
+func (opts transferOptions) chmodCommand(localFile string, remoteFile string) (string, error) {
+	var mode os.FileMode
+	switch {
+	case opts.mode != nil:
+		mode = *opts.mode
+	case opts.preserveMode:
+		fileInfo, err := os.Stat(localFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat local file: %v", err)
+		}
+		mode = fileInfo.Mode().Perm()
+	default:
+		return "", nil
 	}
+	return fmt.Sprintf("chmod %04o %s", mode, wshutils.ShellQuote(remoteFile)), nil
+}
 
-	// 获取gzip压缩数据
-	gzipData := gzipBuffer.Bytes()
+// parseMode 解析八进制权限，如 755 或 0644
+func parseMode(s string) (os.FileMode, error) {
+	value, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || value > 0777 {
+		return 0, fmt.Errorf("invalid --mode '%s', expected octal permissions like 0755", s)
+	}
+	return os.FileMode(value), nil
+}
 
-	// 创建base64编码器
-	var base64Buffer bytes.Buffer
-	encoder := base64.NewEncoder(base64.StdEncoding, &base64Buffer)
+// runPostCommands 执行传输完成后的命令并等待远端确认，确认之前 heredoc 的解码和写入已经完成
+// 没有传输后命令时同样等待确认；远端超过 remoteIdleTimeout 没有输出时返回错误
+func runPostCommands(conn *wshutils.Connection, commands []string) error {
+	fmt.Println("Waiting for the remote to finish...")
+	command := ":"
+	if len(commands) > 0 {
+		command = strings.Join(commands, "; ")
+	}
+	output, status, err := runMarkedCommand(conn, command)
+	if err != nil {
+		return fmt.Errorf("remote did not confirm completion, the transfer may be incomplete: %v", err)
+	}
+	fmt.Print(output)
+	if status != "0" {
+		fmt.Printf("Warning: Post commands exited with status %s\n", status)
+	}
+	conn.CloseGracefully()
+	return nil
+}
 
-	// 写入gzip数据到base64编码器
-	if _, err := encoder.Write(gzipData); err != nil {
-		return "", fmt.Errorf("failed to encode to base64: %v", err)
+// encodeCommand 输出发送给远端的编码数据流，每个数据块一行
+func encodeCommand(localFile string, chunkSize int) error {
+	encodedData, err := wshutils.EncodeFile(localFile)
+	if err != nil {
+		return err
 	}
 
-	// 关闭编码器
-	if err := encoder.Close(); err != nil {
-		return "", fmt.Errorf("failed to close base64 encoder: %v", err)
+	for _, chunk := range wshutils.SplitChunks(encodedData, chunkSize) {
+		fmt.Println(chunk)
 	}
 
-	return base64Buffer.String(), nil
+	return nil
 }
 
-// sendEncodedData 分块发送编码后的数据
-func sendEncodedData(conn *wshutils.Connection, encodedData string) error {
-	data := []byte(encodedData)
-	totalChunks := (len(data) + chunkSize - 1) / chunkSize
-
-	for i := 0; i < totalChunks; i++ {
-		start := i * chunkSize
-		end := start + chunkSize
-		if end > len(data) {
-			end = len(data)
-		}
+// decodeCommand 模拟远端的 base64 --decode |gunzip，还原编码数据流
+func decodeCommand(streamFile string, outputFile string) error {
+	in, err := os.Open(streamFile)
+	if err != nil {
+		return fmt.Errorf("failed to open stream file: %v", err)
+	}
+	defer in.Close()
 
-		chunk := data[start:end]
-		chunkStr := string(chunk)
+	out, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer out.Close()
 
-		// 发送数据块
-		if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: chunkStr + "\n"}); err != nil {
-			return fmt.Errorf("failed to send chunk %d/%d: %v", i+1, totalChunks, err)
-		}
+	if err := wshutils.DecodeStream(in, out); err != nil {
+		return err
 	}
 
-	return nil
+	return out.Close()
 }
 
 // setupTTY 设置tty，禁止回显
 func setupTTY(conn *wshutils.Connection) error {
-	// 发送stty命令来设置tty
-	commands := []string{
-		"stty -echo",    // 禁止回显
-		"stty -icanon",  // 禁用规范模式
-		"stty -isig",    // 禁用信号处理
-		"stty -iexten",  // 禁用扩展输入处理
-		"stty -echoctl", // 禁用控制字符回显
-		"stty -echoke",  // 禁用kill字符回显
-		"stty -echoprt", // 禁用打印回显
-		"stty -echoe",   // 禁用擦除回显
-		"stty -echonl",  // 禁用换行回显
-	}
-
-	for _, cmd := range commands {
+	for _, cmd := range ttyCommands {
 		if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: cmd + "\n"}); err != nil {
 			return fmt.Errorf("failed to send command '%s': %v", cmd, err)
 		}
@@ -300,3 +774,16 @@ func setupTTY(conn *wshutils.Connection) error {
 
 	return nil
 }
+
+// ttyCommands 传输前发送的stty命令
+var ttyCommands = []string{
+	"stty -echo",    // 禁止回显
+	"stty -icanon",  // 禁用规范模式
+	"stty -isig",    // 禁用信号处理
+	"stty -iexten",  // 禁用扩展输入处理
+	"stty -echoctl", // 禁用控制字符回显
+	"stty -echoke",  // 禁用kill字符回显
+	"stty -echoprt", // 禁用打印回显
+	"stty -echoe",   // 禁用擦除回显
+	"stty -echonl",  // 禁用换行回显
+}
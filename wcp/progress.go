@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// progressBarWidth 进度条宽度
+	progressBarWidth = 30
+	// progressRedrawInterval TTY 下重绘进度条的最小间隔
+	progressRedrawInterval = 100 * time.Millisecond
+	// progressLogInterval 非 TTY 下输出进度日志的间隔
+	progressLogInterval = 2 * time.Second
+)
+
+// progressReporter 在 stderr 上报告传输进度，非 TTY 时改为定期输出日志行
+type progressReporter struct {
+	total     int
+	sent      int
+	start     time.Time
+	lastPrint time.Time
+	isTTY     bool
+	// complete 已经输出过 100% 的进度，之后的更新不再重复输出
+	complete bool
+}
+
+// newProgressReporter 创建进度报告器，总字节数由 Update 提供
+func newProgressReporter() *progressReporter {
+	return &progressReporter{
+		start: time.Now(),
+		isTTY: term.IsTerminal(int(os.Stderr.Fd())),
+	}
+}
+
+// Update 记录已发送字节数和总字节数并按需刷新进度，可作为 TransferOptions.Progress 使用
+func (p *progressReporter) Update(sent, total int) {
+	p.sent = sent
+	p.total = total
+
+	interval := progressLogInterval
+	if p.isTTY {
+		interval = progressRedrawInterval
+	}
+	if p.sent < p.total && time.Since(p.lastPrint) < interval {
+		return
+	}
+	// 源文件读取领先于发送，进度可能在发送结束前就到达 100%
+	if p.sent >= p.total {
+		if p.complete {
+			return
+		}
+		p.complete = true
+	}
+	p.lastPrint = time.Now()
+
+	if p.isTTY {
+		fmt.Fprintf(os.Stderr, "\r%s", p.line())
+	} else {
+		fmt.Fprintln(os.Stderr, p.line())
+	}
+}
+
+// Finish 结束进度显示并输出汇总
+func (p *progressReporter) Finish() {
+	if p.isTTY {
+		fmt.Fprintln(os.Stderr)
+	}
+	elapsed := time.Since(p.start)
+	fmt.Fprintf(os.Stderr, "Sent %s in %v (%s/s average)\n",
+		formatKB(p.sent), elapsed.Round(time.Millisecond), formatKB(int(p.rate(elapsed))))
+}
+
+// line 生成一行进度信息
+func (p *progressReporter) line() string {
+	percent := 100.0
+	if p.total > 0 {
+		percent = float64(p.sent) * 100 / float64(p.total)
+	}
+	filled := int(percent / 100 * progressBarWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	return fmt.Sprintf("[%s] %5.1f%%  %s / %s  %s/s",
+		bar, percent, formatKB(p.sent), formatKB(p.total), formatKB(int(p.rate(time.Since(p.start)))))
+}
+
+// rate 计算平均速率（字节/秒）
+func (p *progressReporter) rate(elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(p.sent) / elapsed.Seconds()
+}
+
+// formatKB 以KB为单位格式化字节数
+func formatKB(n int) string {
+	return fmt.Sprintf("%.1f KB", float64(n)/1024)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gitchs/wsh/wshutils"
+)
+
+// remoteIdleTimeout 等待远端命令输出的最长空闲时间
+const remoteIdleTimeout = 30 * time.Second
+
+// readMarkedOutput 读取远端输出，返回起止标记之间的内容和结束标记中的退出状态
+func readMarkedOutput(conn *wshutils.Connection, beginMarker string, endMarker string) (string, string, error) {
+	var pending bytes.Buffer
+	var payload strings.Builder
+	started := false
+	defer conn.GetConn().SetReadDeadline(time.Time{})
+
+	for {
+		conn.GetConn().SetReadDeadline(time.Now().Add(remoteIdleTimeout))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read remote output: %v", err)
+		}
+		pending.Write(msg)
+
+		// 只处理完整的行，不完整的部分留到下一条消息
+		for {
+			line, err := pending.ReadString('\n')
+			if err != nil {
+				pending.Reset()
+				pending.WriteString(line)
+				break
+			}
+			line = strings.TrimRight(line, "\r\n")
+
+			if !started {
+				started = line == beginMarker
+				continue
+			}
+			if strings.HasPrefix(line, endMarker+" ") {
+				return payload.String(), strings.TrimPrefix(line, endMarker+" "), nil
+			}
+			payload.WriteString(line)
+			payload.WriteString("\n")
+		}
+	}
+}
+
+// runMarkedCommand 在远端执行命令，返回其输出和退出状态
+func runMarkedCommand(conn *wshutils.Connection, command string) (string, string, error) {
+	id, err := randomMarkerID()
+	if err != nil {
+		return "", "", err
+	}
+
+	// 标记拆成两段拼接，避免回显的命令行被误认为标记
+	remoteCmd := fmt.Sprintf("echo \"__WCP_\"\"BEGIN_%s\"; %s; echo \"__WCP_\"\"END_%s $?\"\n", id, command, id)
+	if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: remoteCmd}); err != nil {
+		return "", "", fmt.Errorf("failed to send command: %v", err)
+	}
+	return readMarkedOutput(conn, "__WCP_BEGIN_"+id, "__WCP_END_"+id)
+}
+
+// randomMarkerID 生成随机标记，避免与文件内容冲突
+func randomMarkerID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate marker: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// verifyChecksum 比较本地文件和远端文件的 SHA-256，不一致时返回两边的哈希
+func verifyChecksum(conn *wshutils.Connection, localFile string, remoteFile string) error {
+	localSum, err := fileSHA256(localFile)
+	if err != nil {
+		return err
+	}
+	return verifyRemoteSum(conn, localSum, remoteFile)
+}
+
+// verifyRemoteSum 计算远端文件的 SHA-256 并与 localSum 比较
+func verifyRemoteSum(conn *wshutils.Connection, localSum string, remoteFile string) error {
+	output, status, err := runMarkedCommand(conn, checksumCommand(remoteFile))
+	if err != nil {
+		return fmt.Errorf("failed to compute remote checksum: %v", err)
+	}
+	fields := strings.Fields(output)
+	if status != "0" || len(fields) == 0 {
+		return fmt.Errorf("failed to compute remote checksum (status %s), use --no-verify to skip", status)
+	}
+
+	if remoteSum := fields[0]; remoteSum != localSum {
+		return fmt.Errorf("checksum mismatch for '%s': local %s, remote %s", remoteFile, localSum, remoteSum)
+	}
+	return nil
+}
+
+// checksumCommand 计算远端文件 SHA-256 的命令，GNU coreutils 提供 sha256sum，BSD/macOS 使用 shasum
+func checksumCommand(remoteFile string) string {
+	quoted := wshutils.ShellQuote(remoteFile)
+	return fmt.Sprintf("{ sha256sum %s 2>/dev/null || shasum -a 256 %s; }", quoted, quoted)
+}
+
+// fileSHA256 计算本地文件的 SHA-256
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to hash file: %v", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
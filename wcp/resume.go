@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gitchs/wsh/wshutils"
+)
+
+// resumeUpload 可续传的上传：编码后的数据块逐行追加到远端的临时文件，全部到达后再解码
+// 临时文件名包含源文件的 SHA-256 和数据块大小，重新运行时跳过已经完整到达的数据块
+func resumeUpload(conn *wshutils.Connection, localFile string, remoteFile string, opts transferOptions) error {
+	chunkSize := opts.transfer.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = wshutils.DefaultChunkSize
+	}
+
+	sum, err := fileSHA256(localFile)
+	if err != nil {
+		return err
+	}
+	total, err := encodedLength(localFile)
+	if err != nil {
+		return err
+	}
+	part := partPath(remoteFile, sum, chunkSize)
+	quotedPart := wshutils.ShellQuote(part)
+
+	// 查询远端已有的数据，每个数据块在临时文件中占 chunkSize+1 字节（含换行）
+	output, status, err := runMarkedCommand(conn, fmt.Sprintf("{ wc -c < %s; } 2>/dev/null || echo 0", quotedPart))
+	if err != nil {
+		return fmt.Errorf("failed to query partial upload: %v", err)
+	}
+	present, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if status != "0" || err != nil {
+		return fmt.Errorf("unexpected reply when querying partial upload: %q (status %s)", strings.TrimSpace(output), status)
+	}
+
+	lineSize := int64(chunkSize + 1)
+	totalChunks := (total + int64(chunkSize) - 1) / int64(chunkSize)
+	done := min(present/lineSize, totalChunks)
+	if done > 0 {
+		fmt.Printf("Resuming '%s': %d of %d encoded bytes already on the remote\n", localFile, done*int64(chunkSize), total)
+	}
+
+	// 丢弃末尾不完整的数据块
+	if keep := done * lineSize; keep != present {
+		truncateCmd := fmt.Sprintf("head -c %d %s > %s.tmp && mv %s.tmp %s", keep, quotedPart, quotedPart, quotedPart, quotedPart)
+		if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: truncateCmd + "\n"}); err != nil {
+			return fmt.Errorf("failed to truncate partial upload: %v", err)
+		}
+	}
+
+	file, err := os.Open(localFile)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %v", err)
+	}
+	defer file.Close()
+	encoded := wshutils.EncodeStream(file)
+	defer encoded.Close()
+
+	skip := done * int64(chunkSize)
+	if _, err := io.CopyN(io.Discard, encoded, skip); err != nil {
+		return fmt.Errorf("failed to encode file: %v", err)
+	}
+
+	// head -c 读取剩余数据块（含换行）后退出，传输中断时已到达的数据留在临时文件中
+	// 等远端输出就绪标记后再发送数据，否则按块读取输入的 shell（如 dash）会把数据当作后续命令读走
+	id, err := randomMarkerID()
+	if err != nil {
+		return err
+	}
+	remaining := total - skip + (totalChunks - done)
+	appendCmd := fmt.Sprintf("echo \"__WCP_\"\"BEGIN_%s\"; echo \"__WCP_\"\"END_%s 0\"; head -c %d >> %s\n", id, id, remaining, quotedPart)
+	if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: appendCmd}); err != nil {
+		return fmt.Errorf("failed to send append command: %v", err)
+	}
+	if _, _, err := readMarkedOutput(conn, "__WCP_BEGIN_"+id, "__WCP_END_"+id); err != nil {
+		return fmt.Errorf("remote did not start the append command: %v", err)
+	}
+
+	progress := newProgressReporter()
+	buf := make([]byte, chunkSize)
+	sent := skip
+	for {
+		n, err := io.ReadFull(encoded, buf)
+		if n > 0 {
+			if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: string(buf[:n]) + "\n"}); err != nil {
+				return fmt.Errorf("failed to send data at offset %d: %v", sent, err)
+			}
+			sent += int64(n)
+			progress.Update(int(sent), int(total))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to encode file: %v", err)
+		}
+	}
+	progress.Finish()
+
+	// 全部到达后解码，成功时删除临时文件
+	decodeCmd := fmt.Sprintf("base64 --decode < %s |gunzip > %s && rm -f %s", quotedPart, wshutils.ShellQuote(remoteFile), quotedPart)
+	if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: decodeCmd + "\n"}); err != nil {
+		return fmt.Errorf("failed to send decode command: %v", err)
+	}
+	return nil
+}
+
+// encodedLength 计算文件编码后的字节数，编码结果是确定的，续传时据此计算偏移
+func encodedLength(localFile string) (int64, error) {
+	file, err := os.Open(localFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open source file: %v", err)
+	}
+	defer file.Close()
+
+	encoded := wshutils.EncodeStream(file)
+	defer encoded.Close()
+	n, err := io.Copy(io.Discard, encoded)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode file: %v", err)
+	}
+	return n, nil
+}
+
+// partPath 远端临时文件路径，与目标文件在同一目录下
+func partPath(remoteFile string, sum string, chunkSize int) string {
+	return path.Join(path.Dir(remoteFile), fmt.Sprintf(".%s.wcp-%s-%d", path.Base(remoteFile), sum[:12], chunkSize))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/gitchs/wsh/wshutils"
+)
+
+// stdinReader 读取标准输入，同时计算 SHA-256 并统计字节数，超出大小限制时返回错误中止编码
+type stdinReader struct {
+	r     io.Reader
+	limit sizeLimit
+	hash  hash.Hash
+	n     int64
+	// err 超出大小限制的错误，编码协程只能看到包装后的错误，调用方从这里取原始错误
+	err error
+}
+
+// newStdinReader 创建按 limit 限制大小的 stdinReader
+func newStdinReader(r io.Reader, limit sizeLimit) *stdinReader {
+	return &stdinReader{r: r, limit: limit, hash: sha256.New()}
+}
+
+func (s *stdinReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.n += int64(n)
+	s.hash.Write(p[:n])
+	if s.limit.max > 0 && s.n > s.limit.max {
+		s.err = fmt.Errorf("stdin exceeds the %s limit; raise --max-size or use --force (wcp is designed for small file transfers)", s.limit.text)
+		return n, s.err
+	}
+	return n, err
+}
+
+// sum 已读取数据的 SHA-256
+func (s *stdinReader) sum() string {
+	return hex.EncodeToString(s.hash.Sum(nil))
+}
+
+// transferStdin 将标准输入边读边编码发送，远端先写入临时文件，解码成功后再移动到 remoteFile
+// 数据超出大小限制或中途出错时 heredoc 中的 gzip 流不完整，远端 gunzip 失败并删除临时文件，不会留下截断的 remoteFile
+func transferStdin(conn *wshutils.Connection, r io.Reader, remoteFile string, opts transferOptions, limit sizeLimit) (int64, error) {
+	source := newStdinReader(r, limit)
+	encoded := wshutils.EncodeStream(source)
+	defer encoded.Close()
+
+	quoted := wshutils.ShellQuote(remoteFile)
+	quotedTmp := wshutils.ShellQuote(remoteFile + ".wcp-stdin")
+	pipeline := fmt.Sprintf("base64 --decode |gunzip > %s && mv -f %s %s || rm -f %s", quotedTmp, quotedTmp, quoted, quotedTmp)
+	if err := wshutils.SendHeredocReader(conn, pipeline, encoded, opts.transfer); err != nil {
+		if source.err != nil {
+			return source.n, source.err
+		}
+		return source.n, err
+	}
+
+	// 标准输入没有本地权限可以保留，只支持 --mode
+	if opts.mode != nil {
+		chmodCmd := fmt.Sprintf("chmod %04o %s", *opts.mode, quoted)
+		if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: chmodCmd + "\n"}); err != nil {
+			return source.n, fmt.Errorf("failed to send chmod command: %v", err)
+		}
+	}
+
+	if opts.verify {
+		if err := verifyRemoteSum(conn, source.sum(), remoteFile); err != nil {
+			return source.n, err
+		}
+	}
+	return source.n, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// auditLineLimit 审计记录中单行输入的最大长度，超过时先写出已缓存的部分
+const auditLineLimit = 64 << 10
+
+// auditPromptTail 检测密码提示时保留的最近输出长度
+const auditPromptTail = 256
+
+// passwordPrompt 输出末尾出现这样的提示时，下一行输入不写入审计记录
+var passwordPrompt = regexp.MustCompile(`(?i)(password|passphrase|passcode|pin|otp|token|secret)[^\n]*[:?]\s*$`)
+
+// auditLog 记录发送给远端的输入，按行写入 --audit-file，密码提示后的一行和手动暂停期间的输入会被遮盖
+// 输入内容只写入审计记录，普通日志只记录字节数；out 为 nil 时不记录
+type auditLog struct {
+	mu     sync.Mutex
+	out    io.Writer
+	target string
+	line   []byte
+	// 当前行中有需要遮盖的输入
+	lineRedacted bool
+	// 检测到密码提示，遮盖到下一个换行
+	promptSeen bool
+	// 通过 --redact-key 手动暂停记录
+	paused bool
+	// 最近的输出，提示可能被拆成多条消息
+	tail []byte
+}
+
+// audit 当前会话的输入审计，始终非 nil
+var audit = &auditLog{}
+
+// newAuditLog 创建写入 out 的输入审计，target 为连接的端点名称或 URL
+func newAuditLog(out io.Writer, target string) *auditLog {
+	return &auditLog{out: out, target: target}
+}
+
+// Observe 检查远端输出是否以密码提示结尾
+func (a *auditLog) Observe(output []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.tail = append(a.tail, output...)
+	if len(a.tail) > auditPromptTail {
+		a.tail = a.tail[len(a.tail)-auditPromptTail:]
+	}
+	if passwordPrompt.Match(a.tail) {
+		a.promptSeen = true
+	}
+}
+
+// Record 记录一段输入，遇到换行时写出一条审计记录
+func (a *auditLog) Record(input []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, b := range input {
+		if a.paused || a.promptSeen {
+			a.lineRedacted = true
+		}
+		if b == '\r' || b == '\n' {
+			a.flush()
+			// 提示已经被这一行回答，之前的输出不再参与检测
+			a.promptSeen = false
+			a.tail = a.tail[:0]
+			continue
+		}
+		a.line = append(a.line, b)
+		if len(a.line) >= auditLineLimit {
+			a.flush()
+		}
+	}
+}
+
+// Toggle 切换手动暂停记录，返回切换后是否处于暂停状态
+func (a *auditLog) Toggle() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.paused = !a.paused
+	if a.paused {
+		a.lineRedacted = true
+	}
+	return a.paused
+}
+
+// Close 写出还没有遇到换行的输入
+func (a *auditLog) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.line) > 0 || a.lineRedacted {
+		a.flush()
+	}
+}
+
+// flush 写出当前行并清空，调用方需持有锁
+func (a *auditLog) flush() {
+	if a.out != nil {
+		command := fmt.Sprintf("%q", a.line)
+		if a.lineRedacted {
+			command = "[redacted]"
+		}
+		fmt.Fprintf(a.out, "%s [%s] %s\n", time.Now().Format(time.RFC3339), a.target, command)
+	}
+	a.line = a.line[:0]
+	a.lineRedacted = false
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// runCommand 执行单条命令并输出结果，不进入交互模式，返回远端命令的退出码
+// transcript 不为 nil 时同时接收所有输出
+func runCommand(conn *wshutils.Connection, command string, binaryOut *os.File, transcript io.Writer) int {
+	defer conn.CloseGracefully()
+
+	sentinel, err := newCapturingSentinel()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
+	}
+
+	// 关闭回显并清空提示符，与 wcp 的 setupTTY 相同；没有 PTY 的远端上 stty 失败不影响执行
+	if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: "stty -echo 2>/dev/null; PS1=''; PS2=''\n"}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to send command: %v\n", err)
+		return exitDisconnected
+	}
+	audit.Record([]byte(command + "\n"))
+	// 命令前后输出起止标记，只输出两者之间的内容；关闭回显之前已经回显的输入和提示符在起始标记之前，会被丢弃
+	if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: sentinel.BeginCommand() + "\n" + command + "\n" + sentinel.Command() + "\n"}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to send command: %v\n", err)
+		return exitDisconnected
+	}
+
+	// 使用分页程序时先缓存全部输出
+	var output io.Writer = os.Stdout
+	var captured bytes.Buffer
+	if pager != "" {
+		output = &captured
+	}
+	if transcript != nil {
+		output = io.MultiWriter(output, transcript)
+	}
+
+	exitCode := -1
+	for exitCode < 0 {
+		messageType, msg, err := conn.Read()
+		if err != nil {
+			// 没等到结束标记连接就断开了，无法得知命令的退出码
+			output.Write(sentinel.Flush())
+			exitCode = exitOK
+			if reason := wshutils.DisconnectReasonOf(err); reason != wshutils.DisconnectRemoteExit {
+				logrus.WithError(err).Warnf("Disconnected: %s", reason)
+				exitCode = exitDisconnected
+			}
+			break
+		}
+		if stats := conn.Stats(); byteLimitExceeded(stats) {
+			printByteLimitExceeded(stats)
+			return exitByteLimit
+		}
+		if messageType == websocket.BinaryMessage && binaryOut != nil {
+			binaryOut.Write(msg)
+			continue
+		}
+		out, status, done := sentinel.Feed(msg)
+		output.Write(out)
+		if done {
+			exitCode = status
+			// 让远端shell退出，服务端随之关闭连接
+			conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: "exit\n"})
+		}
+	}
+
+	if pager != "" {
+		if err := runPager(resolvePager(pager), captured.Bytes()); err != nil {
+			logrus.WithError(err).Warn("Pager failed, writing output directly")
+			os.Stdout.Write(captured.Bytes())
+		}
+	}
+
+	return exitCode
+}
+
+// commandSentinel 识别命令结束后输出的 "标记 退出码" 行，设置了起始标记时还丢弃起始标记行之前的数据
+type commandSentinel struct {
+	marker string
+	// begin 起始标记，started 为 false 时丢弃数据直到起始标记行结束
+	begin   string
+	started bool
+	// pending 可能是标记开头的数据或不完整的标记行，等待更多数据
+	pending []byte
+}
+
+// newCommandSentinel 创建带随机标记的识别器，结束标记之前的数据全部输出
+func newCommandSentinel() (*commandSentinel, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate command marker: %v", err)
+	}
+	id := hex.EncodeToString(buf)
+	return &commandSentinel{marker: "__WSH_DONE_" + id, begin: "__WSH_BEGIN_" + id, started: true}, nil
+}
+
+// newCapturingSentinel 创建只输出起止标记之间数据的识别器，远端需要先执行 BeginCommand
+func newCapturingSentinel() (*commandSentinel, error) {
+	s, err := newCommandSentinel()
+	if err != nil {
+		return nil, err
+	}
+	s.started = false
+	return s, nil
+}
+
+// BeginCommand 输出起始标记的命令，与 Command 一样拆成两段
+func (s *commandSentinel) BeginCommand() string {
+	return fmt.Sprintf(`echo "%s""%s"`, s.begin[:6], s.begin[6:])
+}
+
+// Command 输出标记和退出码的命令，标记拆成两段，PTY 回显的命令行不会被误认为标记
+func (s *commandSentinel) Command() string {
+	return fmt.Sprintf(`echo "%s""%s $?"`, s.marker[:6], s.marker[6:])
+}
+
+// Feed 处理收到的数据，返回可以输出的部分；遇到标记行时 done 为 true，status 为命令退出码
+func (s *commandSentinel) Feed(data []byte) (out []byte, status int, done bool) {
+	s.pending = append(s.pending, data...)
+
+	if !s.started {
+		i := bytes.Index(s.pending, []byte(s.begin))
+		if i < 0 {
+			// 提示符和回显直接丢弃，只保留末尾可能被截断的起始标记
+			keep := partialMarker(s.pending, s.begin)
+			s.pending = append([]byte(nil), s.pending[len(s.pending)-keep:]...)
+			return nil, 0, false
+		}
+		end := bytes.IndexByte(s.pending[i:], '\n')
+		if end < 0 {
+			s.pending = append([]byte(nil), s.pending[i:]...)
+			return nil, 0, false
+		}
+		s.started = true
+		s.pending = append([]byte(nil), s.pending[i+end+1:]...)
+	}
+
+	if i := bytes.Index(s.pending, []byte(s.marker)); i >= 0 {
+		out = append(out, s.pending[:i]...)
+		line := s.pending[i+len(s.marker):]
+		end := bytes.IndexByte(line, '\n')
+		if end < 0 {
+			s.pending = s.pending[i:]
+			return out, 0, false
+		}
+		// 标记行之后的数据（如新的提示符）留给 Flush
+		s.pending = append([]byte(nil), line[end+1:]...)
+		status, err := strconv.Atoi(strings.TrimSpace(string(line[:end])))
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to parse remote exit status")
+			status = 1
+		}
+		return out, status, true
+	}
+
+	// 末尾可能是被截断的标记，保留到下一块数据
+	keep := partialMarker(s.pending, s.marker)
+	out = append(out, s.pending[:len(s.pending)-keep]...)
+	s.pending = append([]byte(nil), s.pending[len(s.pending)-keep:]...)
+	return out, 0, false
+}
+
+// Flush 返回尚未输出的数据，识别到标记行之后为标记行后面的数据；还没有遇到起始标记时返回 nil
+func (s *commandSentinel) Flush() []byte {
+	pending := s.pending
+	s.pending = nil
+	if !s.started {
+		return nil
+	}
+	return pending
+}
+
+// partialMarker buf 末尾与 marker 开头相同的最长长度（不含完整的 marker）
+func partialMarker(buf []byte, marker string) int {
+	for n := min(len(buf), len(marker)-1); n > 0; n-- {
+		if bytes.HasPrefix([]byte(marker), buf[len(buf)-n:]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// resolvePager 解析分页程序，auto 时依次使用 $PAGER 和 less
+func resolvePager(pager string) string {
+	if pager != "auto" {
+		return pager
+	}
+	if env := os.Getenv("PAGER"); env != "" {
+		return env
+	}
+	return "less"
+}
+
+// runPager 启动分页程序并将输出写入其标准输入
+func runPager(pager string, output []byte) error {
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	_, writeErr := stdin.Write(output)
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("pager '%s' failed: %v", pager, err)
+	}
+
+	// 分页程序提前退出（如在less中按q）会导致EPIPE，不算错误
+	if writeErr != nil && !errors.Is(writeErr, syscall.EPIPE) {
+		return writeErr
+	}
+	return nil
+}
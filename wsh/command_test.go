@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// ptyTranscript 远端 PTY 在关闭回显之前回显了全部输入时的输出
+func ptyTranscript(s *commandSentinel, command, output string, status int) string {
+	return "$ stty -echo 2>/dev/null; PS1=''; PS2=''\r\n" +
+		s.BeginCommand() + "\r\n" + command + "\r\n" + s.Command() + "\r\n" +
+		s.begin + "\r\n" + output + s.marker + " " + strconv.Itoa(status) + "\r\n" +
+		"$ "
+}
+
+func TestCapturingSentinelDropsEchoAndPrompt(t *testing.T) {
+	s, err := newCapturingSentinel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := "Filesystem Size\r\n/dev/sda1 10G\r\n"
+	transcript := ptyTranscript(s, "df -h /", output, 3)
+
+	// 逐字节送入，标记被拆开时也能识别
+	var got strings.Builder
+	status, done := 0, false
+	for i := 0; i < len(transcript) && !done; i++ {
+		var out []byte
+		out, status, done = s.Feed([]byte{transcript[i]})
+		got.Write(out)
+	}
+	if !done {
+		t.Fatal("end marker not recognized")
+	}
+	if status != 3 {
+		t.Errorf("status = %d, want 3", status)
+	}
+	if got.String() != output {
+		t.Errorf("captured %q, want %q", got.String(), output)
+	}
+}
+
+func TestCapturingSentinelFlushBeforeBegin(t *testing.T) {
+	s, err := newCapturingSentinel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, _, done := s.Feed([]byte("$ echo \"__WSH_\"\"BEGIN_...\"\r\n")); len(out) != 0 || done {
+		t.Fatalf("Feed before the begin marker returned %q, done %v", out, done)
+	}
+	// 起始标记之前断开时没有可以输出的内容
+	if rest := s.Flush(); rest != nil {
+		t.Errorf("Flush before the begin marker returned %q", rest)
+	}
+}
+
+func TestCommandSentinelWithoutBegin(t *testing.T) {
+	s, err := newCommandSentinel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, status, done := s.Feed([]byte("output\n" + s.marker + " 0\nprompt$ "))
+	if !done || status != 0 || string(out) != "output\n" {
+		t.Fatalf("Feed = %q, %d, %v", out, status, done)
+	}
+	if rest := string(s.Flush()); rest != "prompt$ " {
+		t.Errorf("Flush = %q, want the data after the marker line", rest)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/spf13/cobra"
+)
+
+// completionConfigFiles 读取正在补全的命令自己的 -c 参数，wsh 和各子命令分别定义了这个参数
+func completionConfigFiles(cmd *cobra.Command) []string {
+	files, err := cmd.Flags().GetStringArray("config")
+	if err != nil {
+		return nil
+	}
+	return files
+}
+
+// completeEndpoints 补全第一个参数为配置中的端点名称，附带描述
+// 配置文件缺失或格式错误时不给出补全，也不输出警告，避免干扰 shell
+func completeEndpoints(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	config, _, err := wshutils.LoadConfigs(resolveConfigPaths(completionConfigFiles(cmd)))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, endpoint := range config.Endpoints {
+		if !strings.HasPrefix(endpoint.Name, toComplete) {
+			continue
+		}
+		if endpoint.Description != "" {
+			names = append(names, endpoint.Name+"\t"+endpoint.Description)
+		} else {
+			names = append(names, endpoint.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfiles 补全 --profile 为配置中的连接配置集名称
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	config, _, err := wshutils.LoadConfigs(resolveConfigPaths(completionConfigFiles(cmd)))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for name := range config.Profiles {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompleteEndpointsUsesCommandConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "other.yaml")
+	content := "endpoints:\n  - name: staging\n    url: ws://localhost/ws\n    description: staging box\n  - name: prod\n    url: ws://localhost/ws\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// wsh ping -c other.yaml <TAB> 读取 ping 自己的 -c，而不是 wsh 的
+	if err := pingCmd.Flags().Set("config", path); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		pingConfigFiles = nil
+		pingCmd.Flags().Lookup("config").Changed = false
+	}()
+
+	names, _ := completeEndpoints(pingCmd, nil, "")
+	if want := []string{"staging\tstaging box", "prod"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("completeEndpoints = %q, want %q", names, want)
+	}
+}
+
+func TestShadowingSubcommand(t *testing.T) {
+	tests := map[string]string{
+		"ping":       "ping",
+		"replay":     "play",
+		"list":       "list",
+		"help":       "help",
+		"completion": "completion",
+		"prod":       "",
+		"encode":     "",
+	}
+	for name, want := range tests {
+		if got := shadowingSubcommand(name); got != want {
+			t.Errorf("shadowingSubcommand(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configDiffJSON  bool
+	configInitForce bool
+	configFilesArg  []string
+)
+
+// exampleConfig config init 写入的示例配置
+const exampleConfig = `# wsh 配置文件，格式说明见 https://github.com/gitchs/wsh#配置文件设置
+
+# 不带参数运行 wsh 时连接的端点
+# default: "dev"
+
+endpoints:
+  - name: "dev"
+    url: "ws://localhost:8080/ws"
+    description: "本地开发服务器"
+
+  - name: "prod"
+    url: "wss://shell.example.com/prod"       # 也可以引用环境变量，如 wss://${GATEWAY_HOST}/shell/prod
+    description: "生产服务器"
+    confirm: true                             # 连接前要求输入端点名称确认
+    # ca_file: "/path/ca.pem"                 # 私有CA证书
+    # headers:                                # 握手时附带的HTTP头
+    #   Authorization: "Bearer ${API_TOKEN}"
+    # heartbeat_interval: 10                  # 心跳间隔（秒）
+
+# 命名的连接配置集：wsh --profile debug
+# profiles:
+#   debug:
+#     endpoint: dev
+#     log-level: debug
+`
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and manage wsh config files",
+}
+
+var configDiffCmd = &cobra.Command{
+	Use:   "diff <old-config> <new-config>",
+	Short: "Show endpoints added, removed or changed between two config files",
+	Args:  cobra.ExactArgs(2),
+	Run:   runConfigDiff,
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a commented example config to the default user config path",
+	Args:  cobra.NoArgs,
+	Run:   runConfigInit,
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the config files wsh loads and the search path",
+	Args:  cobra.NoArgs,
+	Run:   runConfigPath,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Load the config files and report every problem found",
+	Args:  cobra.NoArgs,
+	Run:   runConfigValidate,
+}
+
+func init() {
+	configDiffCmd.Flags().BoolVar(&configDiffJSON, "json", false, "print the diff as JSON")
+	configInitCmd.Flags().BoolVar(&configInitForce, "force", false, "overwrite an existing config file")
+	configValidateCmd.Flags().StringArrayVarP(&configFilesArg, "config", "c", nil, "config file path (repeatable)")
+
+	configCmd.AddCommand(configDiffCmd)
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigDiff(cmd *cobra.Command, args []string) {
+	oldConfig, err := wshutils.LoadConfig(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	newConfig, err := wshutils.LoadConfig(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	diff := wshutils.DiffConfigs(oldConfig, newConfig)
+
+	if configDiffJSON {
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to encode diff: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if diff.Empty() {
+		fmt.Println("No endpoint changes")
+		return
+	}
+	for _, endpoint := range diff.Added {
+		fmt.Printf("+ %-15s %s\n", endpoint.Name, endpoint.URL)
+	}
+	for _, endpoint := range diff.Removed {
+		fmt.Printf("- %-15s %s\n", endpoint.Name, endpoint.URL)
+	}
+	for _, change := range diff.Changed {
+		fmt.Printf("~ %s\n", change.Name)
+		for _, field := range change.Fields {
+			fmt.Printf("    %s: %v -> %v\n", field.Field, field.Old, field.New)
+		}
+	}
+}
+
+func runConfigInit(cmd *cobra.Command, args []string) {
+	// 总是写入用户配置，不写系统级配置
+	path := wshutils.ConfigSearchPath()[0]
+	if _, err := os.Stat(path); err == nil && !configInitForce {
+		fmt.Fprintf(os.Stderr, "Error: %s already exists, use --force to overwrite it\n", path)
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to create config directory: %v\n", err)
+		os.Exit(1)
+	}
+	// headers 中可能有凭据，只允许本人读写
+	if err := os.WriteFile(path, []byte(exampleConfig), 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to write config file: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote example config to %s\n", path)
+}
+
+func runConfigPath(cmd *cobra.Command, args []string) {
+	for _, path := range wshutils.GetDefaultConfigPaths() {
+		fmt.Println(path)
+	}
+
+	fmt.Fprintln(os.Stderr, "Search path:")
+	for _, path := range wshutils.ConfigSearchPath() {
+		status := "missing"
+		if _, err := os.Stat(path); err == nil {
+			status = "found"
+		}
+		fmt.Fprintf(os.Stderr, "  %s (%s)\n", path, status)
+	}
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) {
+	paths := resolveConfigPaths(configFilesArg)
+	// 合并时输出同名端点的警告，同名端点不算错误
+	config, err := loadConfig(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	// 逐个文件检查，问题按文件列出
+	failed := false
+	for _, path := range paths {
+		fileConfig, err := wshutils.LoadConfig(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failed = true
+			continue
+		}
+		if err := fileConfig.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid config file '%s': %v\n", path, err)
+			failed = true
+		}
+	}
+	// 与子命令同名的端点仍然可以通过 default、--profile 和 wcp 使用，只给出警告
+	for _, endpoint := range config.Endpoints {
+		warnShadowedEndpoint(endpoint.Name)
+	}
+	if failed {
+		os.Exit(exitConfigError)
+	}
+	fmt.Printf("OK: %d endpoint(s) in %d file(s)\n", len(config.Endpoints), len(paths))
+}
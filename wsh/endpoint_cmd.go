@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	endpointConfigFile  string
+	endpointDescription string
+)
+
+var endpointCmd = &cobra.Command{
+	Use:   "endpoint",
+	Short: "Add or remove endpoints in a YAML config file",
+}
+
+var endpointAddCmd = &cobra.Command{
+	Use:   "add <name> <url>",
+	Short: "Append an endpoint to the config file",
+	Args:  cobra.ExactArgs(2),
+	Run:   runEndpointAdd,
+}
+
+var endpointRmCmd = &cobra.Command{
+	Use:     "rm <name>",
+	Aliases: []string{"remove"},
+	Short:   "Remove an endpoint from the config file",
+	Args:    cobra.ExactArgs(1),
+	Run:     runEndpointRm,
+}
+
+func init() {
+	endpointCmd.PersistentFlags().StringVarP(&endpointConfigFile, "config", "c", "", "config file to edit (defaults to the user config file)")
+	endpointAddCmd.Flags().StringVar(&endpointDescription, "description", "", "endpoint description")
+
+	endpointCmd.AddCommand(endpointAddCmd)
+	endpointCmd.AddCommand(endpointRmCmd)
+	rootCmd.AddCommand(endpointCmd)
+}
+
+// shadowingSubcommand 返回与端点同名的 wsh 子命令，没有时返回空字符串
+// wsh <name> 总是执行子命令，这样的端点只能通过 default 或 --profile 连接；wcp 和 wsh ping 不受影响
+func shadowingSubcommand(name string) string {
+	// help 和 completion 由 cobra 在执行时才加入
+	if name == "help" || name == "completion" {
+		return name
+	}
+	for _, sub := range rootCmd.Commands() {
+		if sub.Name() == name || slices.Contains(sub.Aliases, name) {
+			return sub.Name()
+		}
+	}
+	return ""
+}
+
+// warnShadowedEndpoint 端点名称与子命令相同时在 stderr 上给出警告
+func warnShadowedEndpoint(name string) {
+	if sub := shadowingSubcommand(name); sub != "" {
+		fmt.Fprintf(os.Stderr, "Warning: endpoint '%s' has the same name as the wsh %s subcommand, 'wsh %s' runs the subcommand; connect through default or --profile, or rename the endpoint\n", name, sub, name)
+	}
+}
+
+// endpointConfigPath 要修改的配置文件，默认是用户配置文件，不修改系统级配置
+func endpointConfigPath() string {
+	if endpointConfigFile != "" {
+		return endpointConfigFile
+	}
+	return wshutils.ConfigSearchPath()[0]
+}
+
+func runEndpointAdd(cmd *cobra.Command, args []string) {
+	path := endpointConfigPath()
+	endpoint := wshutils.Endpoint{Name: args[0], URL: args[1], Description: endpointDescription}
+	if err := wshutils.AddEndpoint(path, endpoint); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Added endpoint '%s' to %s\n", endpoint.Name, path)
+	warnShadowedEndpoint(endpoint.Name)
+}
+
+func runEndpointRm(cmd *cobra.Command, args []string) {
+	path := endpointConfigPath()
+	if err := wshutils.RemoveEndpoint(path, args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Removed endpoint '%s' from %s\n", args[0], path)
+}
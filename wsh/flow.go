@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/sirupsen/logrus"
+)
+
+// flowControlBufferSize 暂停期间最多缓存的输入字节数，超出的输入被丢弃
+const flowControlBufferSize = 64 * 1024
+
+// errInputDropped 暂停期间缓存已满，输入没有发送
+var errInputDropped = errors.New("input dropped while the server is paused")
+
+// inputGate 根据服务端的 pause/resume 消息暂停或恢复用户输入的发送
+// Write 从不阻塞，stdin 循环可以继续读取，断开键和空闲超时在暂停期间仍然有效
+type inputGate struct {
+	conn    *wshutils.Connection
+	notice  io.Writer
+	mutex   sync.Mutex
+	paused  bool
+	pending []byte
+	// dropped 本次暂停期间是否已经提示过丢弃输入
+	dropped bool
+}
+
+// newInputGate 创建输入闸门，丢弃输入时在 notice 上提示一次
+func newInputGate(conn *wshutils.Connection, notice io.Writer) *inputGate {
+	return &inputGate{conn: conn, notice: notice}
+}
+
+// Write 发送用户输入，暂停期间先缓存，缓存满时丢弃并返回 errInputDropped
+func (gate *inputGate) Write(data []byte) error {
+	gate.mutex.Lock()
+	defer gate.mutex.Unlock()
+
+	if gate.conn.IsClosed() {
+		return fmt.Errorf("connection closed")
+	}
+	if !gate.paused {
+		return gate.conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: string(data)})
+	}
+	if len(gate.pending)+len(data) > flowControlBufferSize {
+		if !gate.dropped {
+			gate.dropped = true
+			logrus.Warnf("Server paused input and %d bytes are buffered, dropping input until resume", len(gate.pending))
+			if gate.notice != nil {
+				fmt.Fprintf(gate.notice, "\r\n[wsh] server paused input, buffer full, input dropped until resume\r\n")
+			}
+		}
+		return errInputDropped
+	}
+	gate.pending = append(gate.pending, data...)
+	return nil
+}
+
+// Pause 暂停发送输入
+func (gate *inputGate) Pause() {
+	gate.mutex.Lock()
+	defer gate.mutex.Unlock()
+
+	logrus.Debug("Server requested pause")
+	gate.paused = true
+}
+
+// Resume 恢复发送输入，并先发出暂停期间缓存的内容
+func (gate *inputGate) Resume() {
+	gate.mutex.Lock()
+	defer gate.mutex.Unlock()
+
+	logrus.Debugf("Server requested resume, flushing %d buffered bytes", len(gate.pending))
+	gate.paused = false
+	gate.dropped = false
+	if len(gate.pending) > 0 {
+		if err := gate.conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: string(gate.pending)}); err != nil {
+			logrus.WithError(err).Error("Failed to flush buffered input")
+		}
+		gate.pending = nil
+	}
+}
+
+// Reset 重连后恢复到未暂停的状态，旧连接上的暂停不作用于新连接，缓存的输入丢弃
+func (gate *inputGate) Reset() {
+	gate.mutex.Lock()
+	defer gate.mutex.Unlock()
+
+	if gate.paused || len(gate.pending) > 0 {
+		logrus.Infof("Clearing flow-control pause after reconnect, %d buffered bytes dropped", len(gate.pending))
+	}
+	gate.paused = false
+	gate.dropped = false
+	gate.pending = nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/gorilla/websocket"
+)
+
+// dialGateServer 连接一个把收到的 cmd 内容依次写入 received 的测试服务端
+func dialGateServer(t *testing.T) (*wshutils.Connection, <-chan string) {
+	t.Helper()
+	received := make(chan string, 16)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg wshutils.CmdMsg
+			if json.Unmarshal(data, &msg) == nil && msg.Type == "cmd" {
+				received <- msg.Cmd
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, err := wshutils.NewConnectionWithOptions("ws"+strings.TrimPrefix(server.URL, "http"), wshutils.ConnectionOptions{Quiet: true})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, received
+}
+
+// writeWithin 在 timeout 内完成 gate.Write，否则判定为阻塞
+func writeWithin(t *testing.T, gate *inputGate, data []byte, timeout time.Duration) error {
+	t.Helper()
+	result := make(chan error, 1)
+	go func() { result <- gate.Write(data) }()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		t.Fatal("Write blocked")
+		return nil
+	}
+}
+
+func TestInputGateDropsInsteadOfBlocking(t *testing.T) {
+	conn, received := dialGateServer(t)
+	var notice bytes.Buffer
+	gate := newInputGate(conn, &notice)
+
+	gate.Pause()
+	if err := writeWithin(t, gate, bytes.Repeat([]byte("a"), flowControlBufferSize), time.Second); err != nil {
+		t.Fatalf("buffering up to the limit: %v", err)
+	}
+	// 缓存已满，后续输入丢弃，提示只出现一次
+	for i := 0; i < 3; i++ {
+		if err := writeWithin(t, gate, []byte("b"), time.Second); !errors.Is(err, errInputDropped) {
+			t.Fatalf("Write with a full buffer = %v, want errInputDropped", err)
+		}
+	}
+	if n := strings.Count(notice.String(), "input dropped"); n != 1 {
+		t.Errorf("drop notice shown %d times, want 1", n)
+	}
+
+	gate.Resume()
+	select {
+	case cmd := <-received:
+		if len(cmd) != flowControlBufferSize {
+			t.Errorf("resume flushed %d bytes, want %d", len(cmd), flowControlBufferSize)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("buffered input not flushed on resume")
+	}
+}
+
+func TestInputGateResetClearsPause(t *testing.T) {
+	conn, received := dialGateServer(t)
+	gate := newInputGate(conn, nil)
+
+	// 断线前收到的暂停不作用于重连后的连接
+	gate.Pause()
+	gate.Write([]byte("stale"))
+	gate.Reset()
+	if err := gate.Write([]byte("fresh")); err != nil {
+		t.Fatalf("Write after Reset: %v", err)
+	}
+	select {
+	case cmd := <-received:
+		if cmd != "fresh" {
+			t.Errorf("server received %q, want only the input typed after Reset", cmd)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("input after Reset was not sent")
+	}
+}
+
+func TestInputGateWriteAfterClose(t *testing.T) {
+	conn, _ := dialGateServer(t)
+	gate := newInputGate(conn, nil)
+
+	gate.Pause()
+	conn.Close()
+	if err := writeWithin(t, gate, []byte("x"), time.Second); err == nil {
+		t.Error("Write on a closed connection returned nil")
+	}
+}
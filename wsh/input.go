@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// 终端在 bracketed paste 模式下包裹粘贴内容的控制序列，模式由远端 shell 发送 ESC[?2004h 开启
+var (
+	pasteStart = []byte("\033[200~")
+	pasteEnd   = []byte("\033[201~")
+)
+
+// pasteBufferLimit 等待粘贴结束序列时最多缓存的字节数，超出后直接发送已缓存的内容
+const pasteBufferLimit = 1 << 20
+
+// inputBuffer 整理 stdin 读到的数据再发送：粘贴内容连同首尾的控制序列收集完整后作为一条消息，
+// 末尾不完整的 UTF-8 字符留到下次读取，避免大段粘贴被读取缓冲区拆开后远端收到不完整的粘贴或乱码
+type inputBuffer struct {
+	pending []byte
+}
+
+// Feed 返回可以立即发送的输入；粘贴尚未结束或只有半个字符时返回 nil，内容留待下次读取
+func (b *inputBuffer) Feed(data []byte) []byte {
+	buf := append(b.pending, data...)
+	b.pending = nil
+
+	// 只需要看最后一个开始序列之后是否已经结束
+	start := bytes.LastIndex(buf, pasteStart)
+	if start >= 0 && !bytes.Contains(buf[start:], pasteEnd) && len(buf) <= pasteBufferLimit {
+		b.pending = buf
+		return nil
+	}
+
+	complete, rest := splitIncompleteRune(buf)
+	if len(rest) > 0 {
+		b.pending = append([]byte(nil), rest...)
+	}
+	if len(complete) == 0 {
+		return nil
+	}
+	return complete
+}
+
+// Pending 已缓存、尚未发送的字节数
+func (b *inputBuffer) Pending() int {
+	return len(b.pending)
+}
+
+// splitIncompleteRune 把末尾被截断的多字节 UTF-8 字符分离出来，无效的字节不做处理，原样发送
+func splitIncompleteRune(buf []byte) (complete []byte, rest []byte) {
+	// 从末尾向前找最后一个字符的首字节，最多回看 utf8.UTFMax-1 个后续字节
+	for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(buf[i]) {
+			if buf[i] >= utf8.RuneSelf && !utf8.FullRune(buf[i:]) {
+				return buf[:i], buf[i:]
+			}
+			break
+		}
+	}
+	return buf, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gitchs/wsh/wshutils"
+)
+
+// cjkPaste 约 4KB 的中日韩文本，每个字符 3 个字节
+var cjkPaste = func() string {
+	var sb strings.Builder
+	for sb.Len() < 4096 {
+		sb.WriteString("粘贴的中文内容、日本語のテキスト、한국어 텍스트。\n")
+	}
+	return sb.String()
+}()
+
+// feedReads 依次把每次读取交给 inputBuffer，按实际发送的方式编码为 CmdMsg，返回远端收到的各条消息
+func feedReads(t *testing.T, reads [][]byte) []string {
+	t.Helper()
+	var buffer inputBuffer
+	var received []string
+	for _, read := range reads {
+		input := buffer.Feed(read)
+		if input == nil {
+			continue
+		}
+		data, err := json.Marshal(wshutils.CmdMsg{Type: "cmd", Cmd: string(input)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg wshutils.CmdMsg
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, msg.Cmd)
+	}
+	if buffer.Pending() != 0 {
+		t.Fatalf("%d bytes left in the buffer", buffer.Pending())
+	}
+	return received
+}
+
+func TestInputBufferCJKSplitAtEveryOffset(t *testing.T) {
+	paste := []byte(cjkPaste)
+	for offset := 1; offset < len(paste); offset++ {
+		received := feedReads(t, [][]byte{paste[:offset], paste[offset:]})
+		for i, cmd := range received {
+			if !utf8.ValidString(cmd) {
+				t.Fatalf("offset %d: message %d is not valid UTF-8", offset, i+1)
+			}
+		}
+		if got := strings.Join(received, ""); got != cjkPaste {
+			t.Fatalf("offset %d: joined messages differ from the pasted text", offset)
+		}
+	}
+}
+
+func TestInputBufferCJKSmallReads(t *testing.T) {
+	paste := []byte(cjkPaste)
+	for size := 1; size <= 7; size++ {
+		var reads [][]byte
+		for rest := paste; len(rest) > 0; {
+			n := min(size, len(rest))
+			reads = append(reads, rest[:n])
+			rest = rest[n:]
+		}
+		received := feedReads(t, reads)
+		for i, cmd := range received {
+			if !utf8.ValidString(cmd) {
+				t.Fatalf("read size %d: message %d is not valid UTF-8", size, i+1)
+			}
+		}
+		if got := strings.Join(received, ""); got != cjkPaste {
+			t.Fatalf("read size %d: joined messages differ from the pasted text", size)
+		}
+	}
+}
+
+func TestInputBufferBracketedPaste(t *testing.T) {
+	paste := append(append(append([]byte(nil), pasteStart...), cjkPaste...), pasteEnd...)
+	// 开始序列本身被拆开时无法识别为粘贴，从完整的开始序列之后拆分
+	for offset := len(pasteStart); offset < len(paste); offset += 97 {
+		received := feedReads(t, [][]byte{paste[:offset], paste[offset:]})
+		// 粘贴内容连同控制序列作为一条消息发送
+		if len(received) != 1 || !bytes.Equal([]byte(received[0]), paste) {
+			t.Fatalf("offset %d: got %d messages, want the whole paste in one", offset, len(received))
+		}
+	}
+}
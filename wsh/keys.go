@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// functionKeys xterm 功能键的转义序列
+var functionKeys = map[string][]byte{
+	"f1":  []byte("\x1bOP"),
+	"f2":  []byte("\x1bOQ"),
+	"f3":  []byte("\x1bOR"),
+	"f4":  []byte("\x1bOS"),
+	"f5":  []byte("\x1b[15~"),
+	"f6":  []byte("\x1b[17~"),
+	"f7":  []byte("\x1b[18~"),
+	"f8":  []byte("\x1b[19~"),
+	"f9":  []byte("\x1b[20~"),
+	"f10": []byte("\x1b[21~"),
+	"f11": []byte("\x1b[23~"),
+	"f12": []byte("\x1b[24~"),
+}
+
+// ctrlSymbols 可以与 ctrl 组合的符号，对应 0x1c-0x1f
+const ctrlSymbols = "\\]^_"
+
+// parseKillKey 将 "F12"、"ctrl-]" 这样的按键名称转换为终端发送的字节序列
+func parseKillKey(name string) ([]byte, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if seq, ok := functionKeys[key]; ok {
+		return seq, nil
+	}
+
+	if rest, ok := strings.CutPrefix(key, "ctrl-"); ok && len(rest) == 1 {
+		c := rest[0]
+		switch {
+		case c >= 'a' && c <= 'z':
+			return []byte{c - 'a' + 1}, nil
+		case strings.IndexByte(ctrlSymbols, c) >= 0:
+			return []byte{0x1c + byte(strings.IndexByte(ctrlSymbols, c))}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported key '%s', expected one of %s", name, strings.Join(supportedKillKeys(), ", "))
+}
+
+// keyChord 依次按下的一组按键，如 "ctrl-x ctrl-u"，只有一个键时与 parseKillKey 相同
+type keyChord struct {
+	keys [][]byte
+	// matched 已经按下的前几个键
+	matched int
+}
+
+// parseKeyChord 解析空格分隔的按键名称
+func parseKeyChord(name string) (*keyChord, error) {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty key sequence")
+	}
+	chord := &keyChord{}
+	for _, field := range fields {
+		seq, err := parseKillKey(field)
+		if err != nil {
+			return nil, err
+		}
+		chord.keys = append(chord.keys, seq)
+	}
+	return chord, nil
+}
+
+// First 组合键的第一个键
+func (k *keyChord) First() []byte {
+	return k.keys[0]
+}
+
+// Feed 处理一次读取到的输入，按完整个组合键时 triggered 为 true；几个键可能在同一次读取中到达
+// 组合键的前缀先暂存，后续输入不匹配时与暂存的按键一起通过 forward 返回；forward 为空表示没有需要发送的输入
+func (k *keyChord) Feed(input []byte) (triggered bool, forward []byte) {
+	rest, matched := input, k.matched
+	for matched < len(k.keys) && bytes.HasPrefix(rest, k.keys[matched]) {
+		rest = rest[len(k.keys[matched]):]
+		matched++
+	}
+	if len(rest) == 0 {
+		if matched == len(k.keys) {
+			k.matched = 0
+			return true, nil
+		}
+		k.matched = matched
+		return false, nil
+	}
+
+	held := bytes.Join(k.keys[:k.matched], nil)
+	k.matched = 0
+	return false, append(held, input...)
+}
+
+// supportedKillKeys 列出支持的按键名称，用于错误提示
+func supportedKillKeys() []string {
+	var names []string
+	for name := range functionKeys {
+		names = append(names, strings.ToUpper(name))
+	}
+	sort.Slice(names, func(i, j int) bool {
+		// 按功能键编号排序，F2 排在 F10 之前
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) < len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	return append(names, "ctrl-a..ctrl-z", `ctrl-\`, "ctrl-]", "ctrl-^", "ctrl-_")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/sirupsen/logrus"
+)
+
+// lineModeMaxLine --no-raw 模式下单行输入的最大长度
+const lineModeMaxLine = 1 << 20
+
+// runLineMode --no-raw 模式：不切换 raw 模式，由本地终端处理行编辑，按行发送输入
+// 不同步窗口大小，Ctrl+C、Ctrl+\、Ctrl+Z 产生的信号转发给远端，stdin 结束（Ctrl+D）并等到输出空闲后退出
+// 远端输出写到 stdout，--prefix 时是加上端点标签的 prefixWriter
+func runLineMode(conn *wshutils.Connection, stdout io.Writer, binaryOut *os.File, transcript io.Writer) int {
+	// 远端程序不要输出光标移动等控制序列
+	if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: "export TERM=dumb\n"}); err != nil {
+		logrus.WithError(err).Warn("Failed to set remote TERM")
+	}
+	if err := sendOnConnect(conn); err != nil {
+		logrus.WithError(err).Warn("Failed to send on_connect commands")
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTSTP)
+	defer signal.Stop(sigs)
+	go func() {
+		for sig := range sigs {
+			logrus.Debugf("Forwarding %v to the remote shell", sig)
+			conn.ForwardSignal(sig)
+		}
+	}()
+
+	return runPiped(conn, stdout, binaryOut, transcript, forwardLines)
+}
+
+// forwardLines 按行读取 stdin，每行加上换行作为一条 cmd 消息
+func forwardLines(conn *wshutils.Connection) {
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 4096), lineModeMaxLine)
+	for scanner.Scan() {
+		line := scanner.Text() + "\n"
+		audit.Record([]byte(line))
+		if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: line}); err != nil {
+			logrus.WithError(err).Warn("Failed to forward stdin")
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logrus.WithError(err).Warn("Failed to read stdin")
+	}
+}
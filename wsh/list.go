@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listConfigFiles []string
+	listJSON        bool
+	listTag         string
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list [substring]",
+	Short: "List endpoints, optionally filtered by name, description or tag",
+	Args:  cobra.MaximumNArgs(1),
+	Run:   runList,
+}
+
+// endpointListing list --json 输出的端点信息，不包含可能带凭据的 headers
+type endpointListing struct {
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+func init() {
+	listCmd.Flags().StringArrayVarP(&listConfigFiles, "config", "c", nil, "config file path (repeatable)")
+	listCmd.Flags().BoolVar(&listJSON, "json", false, "print endpoints as JSON")
+	listCmd.Flags().StringVar(&listTag, "tag", "", "only list endpoints with this tag")
+
+	rootCmd.AddCommand(listCmd)
+}
+
+func runList(cmd *cobra.Command, args []string) {
+	config, err := loadConfig(resolveConfigPaths(listConfigFiles))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	query := ""
+	if len(args) > 0 {
+		query = args[0]
+	}
+	endpoints := wshutils.FilterEndpointsByTag(wshutils.SearchEndpoints(config, query), listTag)
+
+	if listJSON {
+		listings := make([]endpointListing, 0, len(endpoints))
+		for _, endpoint := range endpoints {
+			listings = append(listings, endpointListing{Name: endpoint.Name, URL: endpoint.URL, Description: endpoint.Description, Tags: endpoint.Tags})
+		}
+		data, err := json.MarshalIndent(listings, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to encode endpoints: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if len(endpoints) == 0 {
+		fmt.Println("No matching endpoints")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tURL\tTAGS\tDESCRIPTION")
+	for _, endpoint := range endpoints {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", endpoint.Name, endpoint.URL, strings.Join(endpoint.Tags, ","), endpoint.Description)
+	}
+	w.Flush()
+}
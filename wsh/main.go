@@ -2,139 +2,718 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gitchs/wsh/wshutils"
+	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
+// 退出码，脚本可以据此区分会话结束的原因；被 SIGTERM/SIGHUP 终止时为 128+信号编号，
+// 单条命令模式下远端命令执行完成时为远端命令的退出码
+const (
+	// exitOK 远端shell正常退出、按下断开键或 stdin 结束
+	exitOK = 0
+	// exitFailure 连接失败（拨号、握手），以及打开输出文件等本地错误
+	exitFailure = 1
+	// exitDisconnected 连接异常断开（网络中断、读超时、服务端异常关闭）且没有重连成功
+	exitDisconnected = 2
+	// exitConfigError 配置文件、端点名称、配置集或命令行参数有误
+	exitConfigError = 3
+	// exitByteLimit 超出 --max-bytes 流量限制
+	exitByteLimit = 4
+	// exitIdleTimeout 超过 --idle-timeout 没有输入而断开
+	exitIdleTimeout = 5
+)
+
 var (
-	configFile        string
-	heartbeatInterval int
+	configFiles         []string
+	heartbeatInterval   int
+	listEndpoints       bool
+	bindAddr            string
+	profileName         string
+	remoteExitCodes     []int
+	assumeYes           bool
+	setProcTitle        bool
+	noTitle             bool
+	windowTitleOverride string
+	command             string
+	pager               string
+	outputPrefix        string
+	binaryOutPath       string
+	recordPath          string
+	outputPath          string
+	killKey             string
+	idleTimeout         time.Duration
+	proxyURL            string
+	compress            bool
+	predict             bool
+	noRaw               bool
+	noReset             bool
+	passSignals         bool
+	showLatency         bool
+	statusKey           string
+	auditFile           string
+	redactKey           string
+	sendFileKey         string
+	logFormat           string
+	noColor             bool
+	quiet               bool
+	logLevel            string
+	logFile             string
+	noLog               bool
+	maxBytes            string
+	maxBytesDirection   string
+	maxMessageSize      string
+	termSize            string
+	token               string
+	tokenParam          string
+	flowControl         bool
+	caFile              string
+	insecure            bool
+	reconnect           bool
+	reconnectMaxRetries int
+	reconnectBaseDelay  time.Duration
+	reconnectMaxDelay   time.Duration
+	heartbeatMode       string
+	connectTimeout      time.Duration
+	tcpKeepAlive        time.Duration
+	subprotocols        []string
+	readTimeout         time.Duration
+	writeTimeout        time.Duration
+	// maxBytesLimit 解析后的流量限制，0 表示不限制
+	maxBytesLimit int64
+	// logLevelValue 解析后的 --log-level
+	logLevelValue logrus.Level
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "wsh [endpoint-name|websocket-url]",
+	Use:   "wsh [endpoint-name|websocket-url|-] [-- command...]",
 	Short: "WebSocket Shell - Connect to remote shells via WebSocket",
 	Long: `wsh is a WebSocket-based shell client that allows you to connect to remote shells.
 You can connect using predefined endpoints from config file or direct WebSocket URLs.`,
-	Args: cobra.MaximumNArgs(1),
+	Args: validateRootArgs,
 	Run:  runWSH,
 }
 
 func init() {
 	// 定义flags
-	rootCmd.Flags().StringVarP(&configFile, "config", "c", "", "config file path")
+	rootCmd.Flags().StringArrayVarP(&configFiles, "config", "c", nil, "config file path (repeatable, later files override earlier endpoints with the same name)")
+	rootCmd.Flags().BoolVar(&listEndpoints, "list", false, "list endpoints instead of connecting to the default endpoint")
 	rootCmd.Flags().IntVar(&heartbeatInterval, "heartbeat-interval", 15, "heartbeat interval in seconds")
+	rootCmd.Flags().StringVar(&heartbeatMode, "heartbeat-mode", "json", "heartbeat type: json (heartbeat message) or ping (websocket ping frame)")
+	rootCmd.Flags().DurationVar(&connectTimeout, "connect-timeout", wshutils.DefaultConnectTimeout, "timeout for establishing the connection")
+	rootCmd.Flags().StringSliceVar(&subprotocols, "subprotocol", nil, "websocket subprotocol to request via Sec-WebSocket-Protocol, repeatable (overrides the endpoint's subprotocols)")
+	rootCmd.Flags().DurationVar(&tcpKeepAlive, "tcp-keepalive", wshutils.DefaultTCPKeepAlive, "TCP keepalive probe interval for detecting dead peers, 0 to disable")
+	rootCmd.Flags().DurationVar(&readTimeout, "read-timeout", 0, "treat the connection as stalled after this long without any message or pong, e.g. 60s (0 disables)")
+	rootCmd.Flags().DurationVar(&writeTimeout, "write-timeout", 0, "fail a send that blocks longer than this, e.g. 10s (0 disables)")
+	rootCmd.Flags().StringVar(&bindAddr, "bind", "", "local source IP address for the outbound connection")
+	rootCmd.Flags().StringVar(&caFile, "ca-file", "", "PEM CA bundle used to verify wss:// servers (overrides the endpoint's ca_file)")
+	rootCmd.Flags().BoolVar(&insecure, "insecure", false, "skip TLS certificate verification for wss:// connections")
+	rootCmd.Flags().StringVarP(&profileName, "profile", "p", "", "connection profile name from config file")
+	rootCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "skip the confirmation prompt for protected endpoints")
+	rootCmd.Flags().BoolVar(&setProcTitle, "set-proctitle", false, "show the endpoint name in the process title (e.g. 'wsh [prod]')")
+	rootCmd.Flags().BoolVar(&noTitle, "no-title", false, "do not set the terminal window title during an interactive session")
+	rootCmd.Flags().StringVar(&windowTitleOverride, "title", "", "terminal window title for the interactive session (default 'wsh: <endpoint>')")
+	rootCmd.Flags().StringVar(&command, "command", "", "run a single command and exit instead of starting an interactive session")
+	rootCmd.Flags().StringVar(&pager, "pager", "", "pipe --command output through a pager (defaults to $PAGER or less)")
+	rootCmd.Flags().Lookup("pager").NoOptDefVal = "auto"
+	rootCmd.Flags().StringVar(&outputPrefix, "prefix", "", "with --no-raw, prepend a colored label (the endpoint name by default) to each line of server output")
+	rootCmd.Flags().Lookup("prefix").NoOptDefVal = "auto"
+	rootCmd.Flags().BoolVar(&noReset, "no-reset", false, "only restore the saved terminal state on exit instead of clearing the screen and resetting terminal modes")
+	rootCmd.Flags().BoolVar(&noRaw, "no-raw", false, "do not switch the terminal to raw mode; send input line by line (for dumb or CI terminals)")
+	rootCmd.Flags().BoolVar(&passSignals, "pass-signals", false, "do not trap SIGINT, SIGQUIT and SIGTSTP; they act on wsh itself while keystrokes go to the remote as raw bytes")
+	rootCmd.Flags().BoolVar(&predict, "predict", false, "echo typed printable characters locally before the server echoes them (may misrender in full-screen apps)")
+	rootCmd.Flags().BoolVar(&showLatency, "show-latency", false, "measure round-trip latency with websocket pings and show it when --status-key is pressed")
+	rootCmd.Flags().StringVar(&statusKey, "status-key", "F11", "key that prints the connection status line when --show-latency is set")
+	rootCmd.Flags().StringVar(&auditFile, "audit-file", "", "append every line of input sent to the remote, with a timestamp, to this file")
+	rootCmd.Flags().StringVar(&redactKey, "redact-key", "", "key that pauses and resumes input logging while typing a secret (e.g. F10)")
+	rootCmd.Flags().StringVar(&sendFileKey, "send-file-key", "", "key or space-separated key sequence that prompts for a local file and uploads it to the remote (e.g. 'ctrl-x ctrl-u')")
+	rootCmd.Flags().BoolVar(&compress, "compress", false, "negotiate permessage-deflate compression when the server supports it")
+	rootCmd.Flags().StringVar(&proxyURL, "proxy", "", "proxy URL (http:// or socks5://), defaults to HTTP_PROXY/HTTPS_PROXY/ALL_PROXY")
+	rootCmd.Flags().StringVar(&logFormat, "log-format", "text", "log format: text or json")
+	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "suppress connecting messages and info logs on the terminal, keeping only warnings and errors")
+	rootCmd.Flags().BoolVar(&noColor, "no-color", false, "disable colored log output and --prefix labels (also enabled by the NO_COLOR environment variable)")
+	rootCmd.Flags().StringVar(&logLevel, "log-level", "info", "log level: panic, fatal, error, warn, info, debug or trace")
+	rootCmd.Flags().StringVar(&logFile, "log-file", "/tmp/wsh-{pid}.txt", "log file path, {pid} is replaced with the process id, - for stderr, empty to disable")
+	rootCmd.Flags().BoolVar(&noLog, "no-log", false, "disable file logging")
+	rootCmd.Flags().DurationVar(&idleTimeout, "idle-timeout", 0, "disconnect after this long without user input, e.g. 15m (0 disables)")
+	rootCmd.Flags().StringVar(&killKey, "kill-key", "F12", "key that closes the connection: F1-F12, ctrl-a..ctrl-z, ctrl-\\, ctrl-], ctrl-^ or ctrl-_")
+	rootCmd.Flags().StringVar(&outputPath, "output", "", "also write raw session output to a file")
+	rootCmd.Flags().StringVar(&recordPath, "record", "", "record the session output to an asciinema v2 cast file")
+	rootCmd.Flags().StringVar(&binaryOutPath, "binary-out", "", "write binary websocket frames to this file instead of the terminal")
+	rootCmd.Flags().StringVar(&maxBytes, "max-bytes", "", "disconnect after transferring this many bytes (e.g. 10MB)")
+	rootCmd.Flags().StringVar(&token, "token", "", "token added to the handshake URL as a query parameter (${VAR} is expanded, so '${TOKEN}' keeps it out of ps)")
+	rootCmd.Flags().StringVar(&tokenParam, "token-param", "token", "query parameter name used by --token")
+	rootCmd.Flags().StringVar(&termSize, "term-size", "", "terminal size COLSxROWS to report when stdout is not a terminal and COLUMNS/LINES are unset, or none to report nothing")
+	rootCmd.Flags().StringVar(&maxMessageSize, "max-message-size", "1MB", "largest single message accepted from the server (e.g. 4MB)")
+	rootCmd.Flags().StringVar(&maxBytesDirection, "max-bytes-direction", "total", "which traffic counts toward --max-bytes: sent, received or total")
+	rootCmd.Flags().BoolVar(&flowControl, "flow-control", true, "honor pause/resume flow-control messages from the server")
+	rootCmd.Flags().BoolVar(&reconnect, "reconnect", false, "automatically reconnect when the connection drops")
+	rootCmd.Flags().IntVar(&reconnectMaxRetries, "reconnect-max-retries", 10, "maximum reconnect attempts, 0 for unlimited")
+	rootCmd.Flags().DurationVar(&reconnectBaseDelay, "reconnect-base-delay", time.Second, "delay before the first reconnect attempt, doubled after each failure")
+	rootCmd.Flags().DurationVar(&reconnectMaxDelay, "reconnect-max-delay", 30*time.Second, "maximum delay between reconnect attempts")
+	rootCmd.Flags().IntSliceVar(&remoteExitCodes, "remote-exit-codes", wshutils.DefaultRemoteExitCodes, "close codes treated as a normal remote shell exit")
+
+	// --version 输出与 wcp --version 相同格式的构建信息
+	rootCmd.Version = wshutils.GetBuildInfo().String()
+	rootCmd.SetVersionTemplate("wsh {{.Version}}\n")
+
+	// shell 补全端点名称和 --profile
+	rootCmd.ValidArgsFunction = completeEndpoints
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
 }
 
 func setupLogging() {
-	// 创建日志文件
-	pid := os.Getpid()
-	logFile := fmt.Sprintf("/tmp/wsh-%d.txt", pid)
+	// 创建日志文件，"-" 表示写到 stderr，{pid} 替换为进程号，为空或 --no-log 时不记录
+	switch {
+	case noLog || logFile == "":
+		logrus.SetOutput(io.Discard)
+	case logFile == "-":
+		logrus.SetOutput(os.Stderr)
+	default:
+		path := strings.ReplaceAll(logFile, "{pid}", strconv.Itoa(os.Getpid()))
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			// 只提示一次，之后的日志直接丢弃，避免刷屏（如 /tmp 只读）
+			fmt.Fprintf(os.Stderr, "Warning: Failed to open log file, logging disabled: %v\n", err)
+			logrus.SetOutput(io.Discard)
+		} else {
+			logrus.SetOutput(file)
+			logrus.Infof("Log file: %s", path)
+		}
+	}
 
-	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to open log file, using stdout")
-	} else {
-		logrus.SetOutput(file)
-		logrus.Infof("Log file: %s", logFile)
+	// 设置日志级别，建连时被调成了 Error
+	logrus.SetLevel(logLevelValue)
+}
+
+// consoleLogLevel 日志输出到终端时使用的级别，--quiet 时最多输出警告
+func consoleLogLevel() logrus.Level {
+	if quiet && logLevelValue > logrus.WarnLevel {
+		return logrus.WarnLevel
 	}
+	return logLevelValue
+}
 
-	// 设置日志格式
+// setupLogFormat 设置日志格式，在建连之前调用，使所有日志格式一致
+func setupLogFormat() {
+	if logFormat == "json" {
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+		return
+	}
 	logrus.SetFormatter(&logrus.TextFormatter{
 		FullTimestamp: true,
+		DisableColors: colorDisabled(),
 	})
+}
 
-	// 设置日志级别
-	logrus.SetLevel(logrus.InfoLevel)
+// colorDisabled --no-color 或 NO_COLOR 设置为非空值时关闭颜色，见 https://no-color.org
+func colorDisabled() bool {
+	return noColor || os.Getenv("NO_COLOR") != ""
+}
+
+// validateRootArgs "--" 之前最多一个端点参数，之后为要执行的命令
+func validateRootArgs(cmd *cobra.Command, args []string) error {
+	dash := cmd.ArgsLenAtDash()
+	if dash < 0 {
+		return cobra.MaximumNArgs(1)(cmd, args)
+	}
+	if dash > 1 {
+		return fmt.Errorf("accepts at most 1 arg before --, received %d", dash)
+	}
+	if dash == len(args) {
+		return fmt.Errorf("no command given after --")
+	}
+	return nil
 }
 
 func runWSH(cmd *cobra.Command, args []string) {
+	// wsh <endpoint> -- <command...> 等同于 --command
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		if command != "" {
+			fmt.Fprintln(os.Stderr, "Error: --command cannot be combined with -- <command...>")
+			os.Exit(exitConfigError)
+		}
+		command = strings.Join(args[dash:], " ")
+		args = args[:dash]
+	}
+
 	// 确定配置文件路径
-	var configPath string
-	if configFile != "" {
-		configPath = configFile
-	} else {
-		configPath = wshutils.GetDefaultConfigPath()
+	configPaths := resolveConfigPaths(configFiles)
+	configPath := strings.Join(configPaths, ", ")
+
+	// 应用配置集，命令行显式指定的参数优先
+	if profileName != "" {
+		config, err := loadConfig(configPaths)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to load config: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		profile, err := wshutils.FindProfile(config, profileName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		if err := applyProfile(cmd, profile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid profile '%s': %v\n", profileName, err)
+			os.Exit(exitConfigError)
+		}
+		if len(args) == 0 && profile.Endpoint() != "" {
+			args = []string{profile.Endpoint()}
+		}
+	}
+
+	// 目标优先级：参数 > 配置集的端点 > WSH_URL 环境变量 > 默认端点；参数为 "-" 时从 stdin 读取
+	if len(args) > 0 && args[0] == "-" {
+		target, err := readTarget(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitFailure)
+		}
+		args = []string{target}
+	}
+	if len(args) == 0 && !listEndpoints {
+		if target := strings.TrimSpace(os.Getenv(targetEnv)); target != "" {
+			logrus.Infof("Using target from %s", targetEnv)
+			args = []string{target}
+		}
 	}
 
-	// 如果没有参数，显示可用端点
+	// 如果没有参数，连接默认端点；未配置默认端点时在终端上显示端点菜单，不是终端或指定 --list 时显示可用端点
 	if len(args) == 0 {
-		config, err := wshutils.LoadConfig(configPath)
+		config, err := loadConfig(configPaths)
 		if err != nil {
-			fmt.Printf("Error: Failed to load config: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: Failed to load config: %v\n", err)
+		}
+		switch {
+		case !listEndpoints && config != nil && config.Default == "" && canPickEndpoint(config):
+			name, err := pickEndpoint(config)
+			if errors.Is(err, errPickerCanceled) {
+				return
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitFailure)
+			}
+			args = []string{name}
+		case listEndpoints || config == nil || config.Default == "":
+			printAvailableEndpoints(os.Stdout, configPath, config)
+			return
+		default:
+			if _, err := wshutils.FindEndpoint(config, config.Default); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Default endpoint '%s' not found in config %s\n", config.Default, configPath)
+				os.Exit(exitConfigError)
+			}
+			args = []string{config.Default}
 		}
-		printAvailableEndpoints(configPath, config)
-		return
+	}
+
+	if maxBytes != "" {
+		limit, err := wshutils.ParseSize(maxBytes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid --max-bytes: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		maxBytesLimit = limit
+	}
+	messageLimit, err := wshutils.ParseSize(maxMessageSize)
+	if err != nil || messageLimit <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid --max-message-size '%s', expected a positive size like 1MB\n", maxMessageSize)
+		os.Exit(exitConfigError)
+	}
+	if tcpKeepAlive < 0 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid --tcp-keepalive %v, expected a positive duration or 0 to disable\n", tcpKeepAlive)
+		os.Exit(exitConfigError)
+	}
+	// 命令行的 0 表示关闭，ConnectionOptions 中 0 表示默认值
+	if tcpKeepAlive == 0 {
+		tcpKeepAlive = -1
+	}
+	// 行模式下 Ctrl+C 等按键由本地终端转换为信号，不捕获会直接终止 wsh
+	if passSignals && noRaw {
+		fmt.Fprintf(os.Stderr, "Error: --pass-signals cannot be used with --no-raw\n")
+		os.Exit(exitConfigError)
+	}
+	// 全屏程序的输出中插入前缀会打乱画面，只在行模式下提供
+	if outputPrefix != "" && !noRaw {
+		fmt.Fprintf(os.Stderr, "Error: --prefix requires --no-raw\n")
+		os.Exit(exitConfigError)
+	}
+	// stdout 不是终端时上报的窗口大小
+	var fallbackSize wshutils.TermSize
+	skipUnknownSize := termSize == "none"
+	if termSize != "" && !skipUnknownSize {
+		if fallbackSize, err = wshutils.ParseTermSize(termSize); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid --term-size: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+	}
+	if heartbeatMode != "json" && heartbeatMode != "ping" {
+		fmt.Fprintf(os.Stderr, "Error: Invalid --heartbeat-mode '%s', expected json or ping\n", heartbeatMode)
+		os.Exit(exitConfigError)
+	}
+	reconnectOpts := wshutils.ReconnectOptions{
+		BaseDelay:  reconnectBaseDelay,
+		MaxDelay:   reconnectMaxDelay,
+		MaxRetries: reconnectMaxRetries,
+	}
+	if err := reconnectOpts.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid --reconnect-* options: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	if heartbeatInterval <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: Invalid --heartbeat-interval %d, expected a positive number of seconds\n", heartbeatInterval)
+		os.Exit(exitConfigError)
+	}
+	if logFormat != "text" && logFormat != "json" {
+		fmt.Fprintf(os.Stderr, "Error: Invalid --log-format '%s', expected text or json\n", logFormat)
+		os.Exit(exitConfigError)
+	}
+	level, err := logrus.ParseLevel(logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid --log-level: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	logLevelValue = level
+	logrus.SetLevel(consoleLogLevel())
+	setupLogFormat()
+	killSequence, err := parseKillKey(killKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Invalid --kill-key: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	var statusSequence []byte
+	if showLatency {
+		if statusSequence, err = parseKillKey(statusKey); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid --status-key: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		if bytes.Equal(statusSequence, killSequence) {
+			fmt.Fprintf(os.Stderr, "Error: --status-key and --kill-key must be different keys\n")
+			os.Exit(exitConfigError)
+		}
+	}
+	var redactSequence []byte
+	if redactKey != "" {
+		if redactSequence, err = parseKillKey(redactKey); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid --redact-key: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		if bytes.Equal(redactSequence, killSequence) || bytes.Equal(redactSequence, statusSequence) {
+			fmt.Fprintf(os.Stderr, "Error: --redact-key must differ from --kill-key and --status-key\n")
+			os.Exit(exitConfigError)
+		}
+	}
+	var sendFileChord *keyChord
+	if sendFileKey != "" {
+		if sendFileChord, err = parseKeyChord(sendFileKey); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Invalid --send-file-key: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		// 第一个键与其他快捷键相同时会先被它们处理，组合键永远无法按完
+		first := sendFileChord.First()
+		if bytes.Equal(first, killSequence) || bytes.Equal(first, statusSequence) || bytes.Equal(first, redactSequence) {
+			fmt.Fprintf(os.Stderr, "Error: --send-file-key must not start with the --kill-key, --status-key or --redact-key\n")
+			os.Exit(exitConfigError)
+		}
+	}
+	switch maxBytesDirection {
+	case "sent", "received", "total":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Invalid --max-bytes-direction '%s', expected sent, received or total\n", maxBytesDirection)
+		os.Exit(exitConfigError)
 	}
 
 	arg := args[0]
 	var targetURL string
+	// 进程标题中显示的名称，直连URL时使用主机名
+	titleName := arg
 
 	logrus.Infof("Starting wsh with arg: %s, config: %s, heartbeat: %ds", arg, configPath, heartbeatInterval)
 
+	connOpts := wshutils.ConnectionOptions{
+		BindAddr:        bindAddr,
+		CAFile:          caFile,
+		Insecure:        insecure,
+		ConnectTimeout:  connectTimeout,
+		TCPKeepAlive:    tcpKeepAlive,
+		Subprotocols:    subprotocols,
+		Proxy:           proxyURL,
+		Compress:        compress,
+		ReadTimeout:     readTimeout,
+		WriteTimeout:    writeTimeout,
+		MaxMessageSize:  messageLimit,
+		TermSize:        fallbackSize,
+		SkipUnknownSize: skipUnknownSize,
+		RemoteExitCodes: remoteExitCodes,
+		Quiet:           quiet,
+	}
+
 	// 检查是否是预定义的端点名称
 	if !wshutils.IsURL(arg) {
 		// 尝试从配置文件加载端点
-		config, err := wshutils.LoadConfig(configPath)
+		config, err := loadConfig(configPaths)
 		if err != nil {
-			fmt.Printf("Error: Failed to load config: %v\n", err)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "Error: Failed to load config: %v\n", err)
+			os.Exit(exitConfigError)
 		}
 
-		endpoint, err := wshutils.FindEndpoint(config, arg)
+		endpoint, err := wshutils.ResolveEndpoint(config, arg)
+		var ambiguous *wshutils.AmbiguousTagError
+		if errors.As(err, &ambiguous) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintln(os.Stderr, "Specify one of them by name")
+			os.Exit(exitConfigError)
+		}
 		if err != nil {
-			fmt.Printf("Error: Endpoint '%s' not found: %v\n", arg, err)
-			printAvailableEndpoints(configPath, config)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "Error: Endpoint '%s' not found: %v\n", arg, err)
+			// 有相近的端点名时只给出建议，否则列出所有端点
+			if suggestion := wshutils.FormatSuggestions(wshutils.SuggestEndpoints(config, arg)); suggestion != "" {
+				fmt.Fprintln(os.Stderr, suggestion)
+			} else {
+				printAvailableEndpoints(os.Stderr, configPath, config)
+			}
+			os.Exit(exitConfigError)
+		}
+		// 只检查要连接的端点，其他端点写错不影响连接
+		if err := endpoint.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+
+		if !assumeYes {
+			if err := wshutils.ConfirmEndpoint(endpoint, os.Stdin, os.Stderr); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitFailure)
+			}
 		}
 
 		targetURL = endpoint.URL
-		fmt.Printf("Connecting to endpoint '%s' (%s)...\n", endpoint.Name, endpoint.Description)
+		titleName = endpoint.Name
+		connOpts = endpoint.ConnectionOptions(connOpts)
+		onConnect = endpoint.OnConnect
+		if len(onConnect) == 0 {
+			onConnect = config.OnConnect
+		}
+		// 端点的心跳间隔覆盖默认值，命令行显式指定时仍以命令行为准
+		if endpoint.HeartbeatInterval > 0 && !cmd.Flags().Changed("heartbeat-interval") {
+			heartbeatInterval = endpoint.HeartbeatInterval
+			logrus.Infof("Using endpoint heartbeat interval: %ds", heartbeatInterval)
+		}
+		if !quiet {
+			fmt.Fprintf(os.Stderr, "Connecting to endpoint '%s' (%s)...\n", endpoint.Name, endpoint.Description)
+		}
 		logrus.Infof("Using endpoint: %s -> %s", endpoint.Name, endpoint.URL)
 	} else {
 		targetURL = arg
 		logrus.Infof("Using direct URL: %s", targetURL)
+		// ws+unix 地址没有主机名，保留完整地址
+		if u, err := url.Parse(arg); err == nil && u.Host != "" {
+			titleName = u.Host
+		}
+	}
+
+	// --token 覆盖端点配置中的同名查询参数
+	if token != "" {
+		query := make(map[string]string, len(connOpts.Query)+1)
+		for name, value := range connOpts.Query {
+			query[name] = value
+		}
+		query[tokenParam] = token
+		connOpts.Query = query
+	}
+
+	if setProcTitle {
+		if err := wshutils.SetProcTitle(fmt.Sprintf("wsh [%s]", titleName)); err != nil {
+			logrus.WithError(err).Warn("Failed to set process title")
+		}
 	}
 
 	// 创建连接
-	conn, err := wshutils.NewConnection(targetURL)
+	conn, err := wshutils.NewConnectionWithOptions(targetURL, connOpts)
 	if err != nil {
-		fmt.Printf("Error: Failed to connect: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Error: Failed to connect: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	// 正常退出（断开键、stdin 结束）时走关闭握手，出错路径直接 os.Exit
+	defer conn.CloseGracefully()
+
+	// os.Exit 不执行 defer，退出前统一由 cleanup 恢复终端并关闭输出文件
+	// 资源按打开的相反顺序关闭，缓冲中的审计记录先写回文件再关闭
+	var cleanups []func()
+	var cleanupOnce sync.Once
+	cleanup := func() {
+		cleanupOnce.Do(func() {
+			for i := len(cleanups) - 1; i >= 0; i-- {
+				cleanups[i]()
+			}
+		})
+	}
+	defer cleanup()
+	exit := func(code int) {
+		cleanup()
+		os.Exit(code)
 	}
-	defer conn.Close()
 
 	// 连接成功后，设置日志重定向到文件
 	setupLogging()
 	logrus.Info("Connection established, logging redirected to file")
 
 	logrus.Info("Connection established")
+	if len(connOpts.FallbackURLs) > 0 {
+		logrus.Infof("Connected via %s", conn.URL())
+	}
+	if protocol := conn.Subprotocol(); protocol != "" {
+		logrus.Infof("Subprotocol negotiated: %s", protocol)
+	}
+	if compress {
+		if extensions := conn.Extensions(); strings.Contains(extensions, "permessage-deflate") {
+			logrus.Infof("Compression negotiated: %s", extensions)
+		} else {
+			logrus.Info("Server did not accept permessage-deflate, continuing uncompressed")
+		}
+	}
+
+	// 二进制帧单独写入文件，文本帧仍然输出到终端
+	var binaryOut *os.File
+	if binaryOutPath != "" {
+		binaryOut, err = os.OpenFile(binaryOutPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to open binary output file: %v\n", err)
+			exit(exitFailure)
+		}
+		cleanups = append(cleanups, func() { binaryOut.Close() })
+		logrus.Infof("Binary frames redirected to %s", binaryOutPath)
+	}
+
+	// 输出副本：原始输出文件和会话录像
+	var transcripts []io.Writer
+	if outputPath != "" {
+		outputFile, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to open output file: %v\n", err)
+			exit(exitFailure)
+		}
+		cleanups = append(cleanups, func() { outputFile.Close() })
+		transcripts = append(transcripts, outputFile)
+		logrus.Infof("Session output copied to %s", outputPath)
+	}
+	// 会话录像，每条事件立即写入文件，异常退出时仍保留已录制的部分
+	if recordPath != "" {
+		recordFile, err := os.OpenFile(recordPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to open record file: %v\n", err)
+			exit(exitFailure)
+		}
+		cleanups = append(cleanups, func() { recordFile.Close() })
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil || width == 0 || height == 0 {
+			width, height = 80, 24
+		}
+		recorder, err := wshutils.NewCastWriter(recordFile, width, height)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to start recording: %v\n", err)
+			exit(exitFailure)
+		}
+		transcripts = append(transcripts, recorder)
+		logrus.Infof("Recording session to %s", recordPath)
+	}
+	var transcript io.Writer
+	if len(transcripts) > 0 {
+		transcript = io.MultiWriter(transcripts...)
+	}
+
+	// 输入审计记录，追加写入，多次会话保存在同一个文件中
+	if auditFile != "" {
+		auditOut, err := os.OpenFile(auditFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to open audit file: %v\n", err)
+			exit(exitFailure)
+		}
+		cleanups = append(cleanups, func() { auditOut.Close() })
+		audit = newAuditLog(auditOut, titleName)
+		cleanups = append(cleanups, audit.Close)
+		logrus.Infof("Auditing input to %s", auditFile)
+	}
+
+	// 单条命令模式，不切换 raw 模式
+	if command != "" {
+		if err := sendOnConnect(conn); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to send on_connect commands: %v\n", err)
+			exit(exitDisconnected)
+		}
+		exit(runCommand(conn, command, binaryOut, transcript))
+	}
+
+	// 行模式，由本地终端处理行编辑
+	if noRaw {
+		var stdout io.Writer = os.Stdout
+		if outputPrefix != "" {
+			label := outputPrefix
+			if label == "auto" {
+				label = titleName
+			}
+			stdout = newPrefixWriter(os.Stdout, label, !colorDisabled())
+		}
+		exit(runLineMode(conn, stdout, binaryOut, transcript))
+	}
+
+	// stdin 不是终端（管道或重定向）时转发输入，不进入交互模式
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		if err := sendOnConnect(conn); err != nil {
+			logrus.WithError(err).Warn("Failed to send on_connect commands")
+		}
+		exit(runPiped(conn, os.Stdout, binaryOut, transcript, forwardChunks))
+	}
 
 	// 切换终端 raw 模式
 	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
 	if err != nil {
-		fmt.Printf("Error: Failed to set terminal raw mode: %v\n", err)
-		os.Exit(1)
-	}
-	defer func() {
-		// 恢复终端状态
-		term.Restore(int(os.Stdin.Fd()), oldState)
-		// 重置终端，模仿reset命令的行为
-		resetTerminal()
-
-		// 将日志重定向到console
-		logrus.SetOutput(os.Stdout)
-		logrus.Infof("wsh exited, terminal reset completed")
-	}()
+		fmt.Fprintf(os.Stderr, "Error: Failed to set terminal raw mode: %v (try --no-raw)\n", err)
+		exit(exitFailure)
+	}
+	// 窗口标题显示端点名称，区分同时打开的多个会话
+	showTitle := !noTitle && term.IsTerminal(int(os.Stdout.Fd()))
+	if showTitle {
+		setWindowTitle(os.Stdout, windowTitle(windowTitleOverride, titleName))
+	}
+	// 终端恢复可能在 defer 或退出路径上触发，只执行一次
+	var restoreOnce sync.Once
+	restoreTerminal := func() {
+		restoreOnce.Do(func() {
+			// 恢复终端状态
+			term.Restore(int(os.Stdin.Fd()), oldState)
+			if showTitle {
+				resetWindowTitle(os.Stdout)
+			}
+			// 将日志重定向到console，stdout 只留给远端的输出
+			logrus.SetOutput(os.Stderr)
+			logrus.SetLevel(consoleLogLevel())
+
+			// 重置终端，模仿reset命令的行为；--no-reset 时保留屏幕内容
+			if noReset {
+				logrus.Infof("wsh exited, terminal restored")
+				return
+			}
+			resetTerminal()
+			logrus.Infof("wsh exited, terminal reset completed")
+		})
+	}
+	cleanups = append(cleanups, restoreTerminal)
 
 	// 记录最后发送消息的时间
 	var lastSendTime time.Time
@@ -148,20 +727,37 @@ func runWSH(cmd *cobra.Command, args []string) {
 		logrus.Debug("Updated last send time")
 	}
 
+	// 记录最后一次用户输入的时间，心跳和窗口大小消息不计入
+	var lastInputTime atomic.Int64
+	lastInputTime.Store(time.Now().UnixNano())
+
 	// 设置信号处理器
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGWINCH)
+	// raw 模式下 Ctrl+C、Ctrl+\、Ctrl+Z 作为普通字节发送，这里处理的是 kill 等外部发来的信号
+	// --pass-signals 时不捕获这些信号，按默认方式作用于 wsh 本身
+	// SIGTERM、SIGHUP 总是捕获，退出前恢复终端，避免终端停留在 raw 模式
+	trapped := []os.Signal{syscall.SIGWINCH, syscall.SIGTERM, syscall.SIGHUP}
+	if !passSignals {
+		trapped = append(trapped, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTSTP)
+	}
+	signal.Notify(sigs, trapped...)
 	go func() {
 		for sig := range sigs {
 			switch sig {
-			case syscall.SIGINT:
-				logrus.Debug("Sending Ctrl+C")
-				conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: string([]byte{3})}) // Ctrl+C
-				updateLastSendTime()
 			case syscall.SIGWINCH:
 				logrus.Debug("Window size changed, sending resize")
 				conn.ResizeTerm()
 				updateLastSendTime()
+			case syscall.SIGTERM, syscall.SIGHUP:
+				logrus.Infof("Received %v, closing connection", sig)
+				restoreTerminal()
+				conn.CloseGracefully()
+				// 与被信号终止的进程一样以 128+信号编号 退出
+				exit(128 + int(sig.(syscall.Signal)))
+			default:
+				logrus.Debugf("Forwarding %v to the remote shell", sig)
+				conn.ForwardSignal(sig)
+				updateLastSendTime()
 			}
 		}
 	}()
@@ -173,7 +769,12 @@ func runWSH(cmd *cobra.Command, args []string) {
 
 		var lastCols, lastRows int
 
-		for range ticker.C {
+		for {
+			select {
+			case <-conn.Done():
+				return
+			case <-ticker.C:
+			}
 			cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
 			if err != nil {
 				continue
@@ -189,49 +790,182 @@ func runWSH(cmd *cobra.Command, args []string) {
 		}
 	}()
 
-	// 启动智能心跳
-	go func() {
-		ticker := time.NewTicker(1 * time.Second) // 每秒检查一次
-		defer ticker.Stop()
+	// 启动心跳，ping 模式使用 WebSocket 控制帧定时发送
+	if heartbeatMode == "ping" {
+		conn.StartPingHeartbeat(time.Duration(heartbeatInterval) * time.Second)
+	} else {
+		// 智能心跳
+		go func() {
+			ticker := time.NewTicker(1 * time.Second) // 每秒检查一次
+			defer ticker.Stop()
 
-		for range ticker.C {
-			lastSendMutex.Lock()
-			timeSinceLastSend := time.Since(lastSendTime)
-			lastSendMutex.Unlock()
+			for {
+				select {
+				case <-conn.Done():
+					return
+				case <-ticker.C:
+				}
+				lastSendMutex.Lock()
+				timeSinceLastSend := time.Since(lastSendTime)
+				lastSendMutex.Unlock()
 
-			// 如果超过设定时间没有发送消息，发送心跳
-			if timeSinceLastSend > time.Duration(heartbeatInterval)*time.Second {
-				logrus.Debugf("Sending heartbeat (last send: %v ago)", timeSinceLastSend)
-				conn.SendJSON(wshutils.HeartbeatMsg{Type: "heartbeat", Data: ""})
-				updateLastSendTime()
+				// 如果超过设定时间没有发送消息，发送心跳
+				if timeSinceLastSend > time.Duration(heartbeatInterval)*time.Second {
+					logrus.Debugf("Sending heartbeat (last send: %v ago)", timeSinceLastSend)
+					conn.SendJSON(wshutils.HeartbeatMsg{Type: "heartbeat", Data: ""})
+					updateLastSendTime()
+				}
 			}
+		}()
+	}
+
+	// 定时发送 ping 测量往返时间，按状态键时显示
+	if showLatency {
+		conn.StartLatencyProbe(latencyProbeInterval)
+	}
+
+	// 用户输入经过流控闸门发送，缓存满时丢弃输入并在终端提示
+	gate := newInputGate(conn, os.Stdout)
+	if flowControl {
+		conn.HandleMessage(wshutils.PauseMsgType, func([]byte) { gate.Pause() })
+		conn.HandleMessage(wshutils.ResumeMsgType, func([]byte) { gate.Resume() })
+	}
+
+	// 流量超出限制时断开连接
+	if maxBytesLimit > 0 {
+		go func() {
+			ticker := time.NewTicker(200 * time.Millisecond)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-conn.Done():
+					return
+				case <-ticker.C:
+				}
+				if stats := conn.Stats(); byteLimitExceeded(stats) {
+					logrus.Warnf("Byte limit exceeded: sent %d, received %d", stats.BytesSent, stats.BytesReceived)
+					restoreTerminal()
+					printByteLimitExceeded(stats)
+					conn.Close()
+					exit(exitByteLimit)
+				}
+			}
+		}()
+	}
+
+	// 长时间没有用户输入时断开连接
+	if idleTimeout > 0 {
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-conn.Done():
+					return
+				case <-ticker.C:
+				}
+				idle := time.Since(time.Unix(0, lastInputTime.Load()))
+				if idle > idleTimeout {
+					logrus.Warnf("No input for %v, disconnecting", idle.Round(time.Second))
+					restoreTerminal()
+					fmt.Fprintf(os.Stderr, "Disconnected due to inactivity (no input for %v)\n", idleTimeout)
+					conn.Close()
+					exit(exitIdleTimeout)
+				}
+			}
+		}()
+	}
+
+	// 建连和重连后都需要发送的初始化消息
+	sendInitialMessages := func() {
+		// 先发一次窗口大小
+		conn.ResizeTerm()
+		updateLastSendTime()
+
+		// 发送必要的环境变量
+		conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: "export TERM=xterm-256color\n"})
+		updateLastSendTime()
+
+		// 初始化命令在读取 stdin 之前发出，用户开始输入时提示符已经设置好
+		if err := sendOnConnect(conn); err != nil {
+			logrus.WithError(err).Warn("Failed to send on_connect commands")
 		}
-	}()
+		updateLastSendTime()
+	}
+
+	// 终端输出同时写入输出副本，控制序列原样保留
+	// 本地回显只写终端，不进入会话记录
+	terminalOut := io.Writer(os.Stdout)
+	var echo *localEcho
+	if predict {
+		echo = newLocalEcho(os.Stdout)
+		terminalOut = echo
+	}
+	sessionOut := terminalOut
+	if transcript != nil {
+		sessionOut = io.MultiWriter(terminalOut, transcript)
+	}
+
+	// --send-file-key 上传文件期间由它接管远端输出
+	upload := newFileUpload(sessionOut)
 
 	// 接收服务端 raw 数据
 	go func() {
 		for {
-			_, msg, err := conn.ReadMessage()
+			messageType, msg, err := conn.Read()
 			if err != nil {
-				logrus.WithError(err).Info("Connection closed")
-				os.Exit(0)
+				// 用户按下断开键主动关闭，由主循环负责退出
+				if conn.IsClosed() {
+					return
+				}
+				// 区分远端shell正常退出、可以重连的中断和重连也无济于事的错误
+				reason := wshutils.DisconnectReasonOf(err)
+				if reason == wshutils.DisconnectRemoteExit {
+					logrus.WithError(err).Info("Remote shell exited")
+					exit(exitOK)
+				}
+				if reconnect && reason.Reconnectable() {
+					logrus.WithError(err).Warnf("Disconnected: %s, reconnecting", reason)
+					reconnectErr := conn.Reconnect(reconnectOpts)
+					if reconnectErr == nil {
+						gate.Reset()
+						sendInitialMessages()
+						continue
+					}
+					if conn.IsClosed() {
+						return
+					}
+					err = reconnectErr
+				}
+				logrus.WithError(err).Warnf("Disconnected: %s", reason)
+				restoreTerminal()
+				fmt.Fprintf(os.Stderr, "Error: Disconnected (%s): %v\n", reason, err)
+				exit(exitDisconnected)
+			}
+			if messageType == websocket.TextMessage && conn.DispatchMessage(msg) {
+				continue
 			}
-			os.Stdout.Write(msg)
+			if messageType == websocket.BinaryMessage && binaryOut != nil {
+				binaryOut.Write(msg)
+				continue
+			}
+			audit.Observe(msg)
+			if upload.Filter(msg) {
+				continue
+			}
+			sessionOut.Write(msg)
 		}
 	}()
 
-	// 启动时先发一次窗口大小
-	conn.ResizeTerm()
-	updateLastSendTime()
-
-	// 发送必要的环境变量
-	conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: "export TERM=xterm-256color\n"})
-	updateLastSendTime()
+	sendInitialMessages()
 
 	logrus.Info("Entering interactive mode")
 
 	// 从 stdin 读输入并发 JSON
 	buf := make([]byte, 1024)
+	var stdinBuffer inputBuffer
 	for {
 		n, err := os.Stdin.Read(buf)
 		if err != nil {
@@ -239,66 +973,145 @@ func runWSH(cmd *cobra.Command, args []string) {
 			return
 		}
 
-		logrus.Debugf("Sending user input: %d bytes", n)
-
-		if bytes.Equal(buf[:n], []byte{27, 91, 50, 52, 126}) {
-			// 预留F12，用来杀连接
-			logrus.Info("F12 pressed, closing connection")
-			conn.Close()
+		if bytes.Equal(buf[:n], killSequence) {
+			// 预留断开键（默认F12），用来杀连接
+			logrus.Infof("%s pressed, closing connection", killKey)
+			conn.CloseGracefully()
 			break
 		}
 
-		conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: string(buf[:n])})
-		updateLastSendTime()
-	}
-}
+		if statusSequence != nil && bytes.Equal(buf[:n], statusSequence) {
+			// 状态行只显示在本地终端，不发送给服务端
+			fmt.Fprintf(terminalOut, "\r\n%s\r\n", formatStatus(conn, time.Now()))
+			continue
+		}
 
-func resetTerminal() {
-	// 发送reset命令的终端控制序列
-	// 这些序列模仿reset命令的行为
+		if redactSequence != nil && bytes.Equal(buf[:n], redactSequence) {
+			state := "resumed"
+			if audit.Toggle() {
+				state = "paused"
+			}
+			logrus.Infof("Input logging %s", state)
+			fmt.Fprintf(terminalOut, "\r\n[wsh] input logging %s\r\n", state)
+			continue
+		}
 
-	// 1. 清除屏幕并移动光标到左上角
-	fmt.Print("\033[2J")
+		data := buf[:n]
+		if sendFileChord != nil {
+			triggered, forward := sendFileChord.Feed(data)
+			if triggered {
+				upload.Run(conn, oldState)
+				updateLastSendTime()
+				lastInputTime.Store(time.Now().UnixNano())
+				continue
+			}
+			if len(forward) == 0 {
+				// 组合键按到一半，等待下一个键
+				continue
+			}
+			data = forward
+		}
 
-	// 2. 移动光标到第一行第一列
-	fmt.Print("\033[H")
+		input := stdinBuffer.Feed(data)
+		if input == nil {
+			logrus.Debugf("Buffering incomplete input: %d bytes", stdinBuffer.Pending())
+			continue
+		}
 
-	// 3. 重置所有属性（颜色、样式等）
-	fmt.Print("\033[0m")
+		// 输入内容可能包含密码，只写入 --audit-file，日志中只记录长度
+		audit.Record(input)
+		logrus.Debugf("Sending user input: %d bytes", len(input))
 
-	// 4. 重置光标形状
-	fmt.Print("\033[0 q")
+		if echo != nil {
+			echo.Predict(input)
+		}
+		if err := gate.Write(input); err != nil && conn.IsClosed() {
+			logrus.WithError(err).Info("Connection closed, stopping input")
+			return
+		}
+		updateLastSendTime()
+		lastInputTime.Store(time.Now().UnixNano())
+	}
+}
 
-	// 5. 显示光标
-	fmt.Print("\033[?25h")
+// byteLimitExceeded 判断流量是否超出 --max-bytes 限制
+func byteLimitExceeded(stats wshutils.ConnectionStats) bool {
+	if maxBytesLimit <= 0 {
+		return false
+	}
+	switch maxBytesDirection {
+	case "sent":
+		return stats.BytesSent > maxBytesLimit
+	case "received":
+		return stats.BytesReceived > maxBytesLimit
+	default:
+		return stats.Total() > maxBytesLimit
+	}
+}
 
-	// 6. 重置键盘模式
-	fmt.Print("\033[?1l")
+// printByteLimitExceeded 提示流量超出限制
+func printByteLimitExceeded(stats wshutils.ConnectionStats) {
+	fmt.Fprintf(os.Stderr, "Disconnected: %s byte limit of %s exceeded (sent %d bytes, received %d bytes)\n",
+		maxBytesDirection, maxBytes, stats.BytesSent, stats.BytesReceived)
+}
 
-	// 7. 重置终端模式
-	fmt.Print("\033[?7h")
+// applyProfile 将配置集中的设置当作命令行参数应用，已显式指定的参数不会被覆盖
+// 通过 cmd.Flags().Set 设置，参数标记为 Changed，与命令行上给出的参数一样优先于端点配置
+func applyProfile(cmd *cobra.Command, profile wshutils.Profile) error {
+	for _, name := range profile.FlagNames() {
+		if name == "config" || name == "profile" {
+			return fmt.Errorf("'%s' cannot be set from a profile", name)
+		}
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil {
+			return fmt.Errorf("unknown flag '%s'", name)
+		}
+		if flag.Changed {
+			continue
+		}
+		for _, value := range profile.FlagValues(name) {
+			if err := cmd.Flags().Set(name, value); err != nil {
+				return fmt.Errorf("invalid value '%s' for '%s': %v", value, name, err)
+			}
+		}
+	}
+	return nil
+}
 
-	// 8. 重置自动换行
-	fmt.Print("\033[?25h")
+// resolveConfigPaths 未通过 -c 指定时使用默认配置文件列表
+func resolveConfigPaths(files []string) []string {
+	if len(files) > 0 {
+		return files
+	}
+	logrus.Debugf("Config search path: %s", strings.Join(wshutils.ConfigSearchPath(), ", "))
+	return wshutils.GetDefaultConfigPaths()
+}
 
-	logrus.Debug("Terminal reset completed")
+// loadConfig 加载并合并配置文件，重复定义的端点在 stderr 上给出警告
+func loadConfig(configPaths []string) (*wshutils.Config, error) {
+	config, warnings, err := wshutils.LoadConfigs(configPaths)
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	return config, err
 }
 
-func printAvailableEndpoints(configPath string, config *wshutils.Config) {
-	fmt.Printf("Config file: %s\n", configPath)
-	fmt.Println("")
+// printAvailableEndpoints 列出可用端点，--list 时写到 stdout，作为错误提示时写到 stderr
+func printAvailableEndpoints(out io.Writer, configPath string, config *wshutils.Config) {
+	fmt.Fprintf(out, "Config file: %s\n", configPath)
+	fmt.Fprintln(out, "")
 	if config != nil && len(config.Endpoints) > 0 {
-		fmt.Println("Available endpoints:")
+		fmt.Fprintln(out, "Available endpoints:")
 		for _, endpoint := range config.Endpoints {
-			fmt.Printf("  %-15s - %s\n", endpoint.Name, endpoint.Description)
+			fmt.Fprintf(out, "  %-15s - %s\n", endpoint.Name, endpoint.Description)
 		}
-		fmt.Println("")
+		fmt.Fprintln(out, "")
 	}
 }
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Printf("Error: Command execution failed: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Error: Command execution failed: %v\n", err)
+		os.Exit(exitConfigError)
 	}
 }
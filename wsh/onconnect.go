@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/sirupsen/logrus"
+)
+
+// onConnect 连接建立后依次发送的命令，取自端点的 on_connect，端点未配置时使用全局的 on_connect
+var onConnect []string
+
+// sendOnConnect 在 TERM 设置之后、接受用户输入之前发送 on_connect 命令，每条命令以换行结尾
+func sendOnConnect(conn *wshutils.Connection) error {
+	for _, command := range onConnect {
+		if !strings.HasSuffix(command, "\n") {
+			command += "\n"
+		}
+		logrus.Debugf("Sending on_connect command: %q", command)
+		audit.Record([]byte(command))
+		if err := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: command}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
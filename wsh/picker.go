@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/gitchs/wsh/wshutils"
+	"golang.org/x/term"
+)
+
+// errPickerCanceled 用户在端点菜单中取消了选择
+var errPickerCanceled = errors.New("no endpoint selected")
+
+// endpointPicker 不带参数运行时的端点菜单：方向键或 j/k 移动，输入编号跳转，回车连接，q、Esc 或 Ctrl+C 取消
+type endpointPicker struct {
+	endpoints []wshutils.Endpoint
+	selected  int
+	// typed 已输入的编号
+	typed string
+}
+
+// canPickEndpoint stdin 和 stderr 都是终端时才显示菜单，否则仍然打印端点列表
+func canPickEndpoint(config *wshutils.Config) bool {
+	return config != nil && len(config.Endpoints) > 0 &&
+		term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// pickEndpoint 在 stderr 上显示端点菜单，返回选中的端点名称
+func pickEndpoint(config *wshutils.Config) (string, error) {
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", fmt.Errorf("failed to set terminal raw mode: %v", err)
+	}
+	defer term.Restore(int(os.Stdin.Fd()), oldState)
+
+	picker := &endpointPicker{endpoints: config.Endpoints}
+	out := os.Stderr
+	fmt.Fprint(out, "Select an endpoint (arrows or number, Enter to connect, q to cancel):\r\n")
+	picker.render(out)
+
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return "", fmt.Errorf("failed to read selection: %v", err)
+		}
+		done, canceled := picker.handle(buf[:n])
+		if canceled {
+			return "", errPickerCanceled
+		}
+		// 光标移回菜单第一行后重画
+		fmt.Fprintf(out, "\033[%dA", len(picker.endpoints))
+		picker.render(out)
+		if done {
+			return picker.endpoints[picker.selected].Name, nil
+		}
+	}
+}
+
+// handle 处理一次读取到的按键，返回是否已确认选择、是否取消
+func (p *endpointPicker) handle(key []byte) (done bool, canceled bool) {
+	switch string(key) {
+	case "\r", "\n":
+		return true, false
+	case "\x03", "\x1b", "q":
+		return false, true
+	case "\x1b[A", "\x1bOA", "k":
+		p.move(-1)
+		return false, false
+	case "\x1b[B", "\x1bOB", "j":
+		p.move(1)
+		return false, false
+	case "\x7f", "\b":
+		if p.typed != "" {
+			p.typed = p.typed[:len(p.typed)-1]
+			p.jump()
+		}
+		return false, false
+	}
+
+	// 编号可以有多位，超出范围的输入重新开始
+	if len(key) == 1 && key[0] >= '0' && key[0] <= '9' {
+		p.typed += string(key)
+		if i, _ := strconv.Atoi(p.typed); i < 1 || i > len(p.endpoints) {
+			p.typed = string(key)
+		}
+		p.jump()
+	}
+	return false, false
+}
+
+// move 上下移动选中项，到头后从另一端继续
+func (p *endpointPicker) move(delta int) {
+	p.typed = ""
+	p.selected = (p.selected + delta + len(p.endpoints)) % len(p.endpoints)
+}
+
+// jump 选中已输入的编号对应的端点
+func (p *endpointPicker) jump() {
+	if i, err := strconv.Atoi(p.typed); err == nil && i >= 1 && i <= len(p.endpoints) {
+		p.selected = i - 1
+	}
+}
+
+// render 输出菜单，选中项前面显示 >，raw 模式下用 \r\n 换行
+func (p *endpointPicker) render(out io.Writer) {
+	for i, endpoint := range p.endpoints {
+		marker := " "
+		if i == p.selected {
+			marker = ">"
+		}
+		fmt.Fprintf(out, "\r\033[K%s %2d) %-15s %s\r\n", marker, i+1, endpoint.Name, endpoint.Description)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pingConfigFiles []string
+	pingAll         bool
+	pingTimeout     time.Duration
+	pingHeartbeat   bool
+	pingInsecure    bool
+)
+
+var pingCmd = &cobra.Command{
+	Use:   "ping [endpoint-name|websocket-url]",
+	Short: "Check that an endpoint is reachable without opening a shell",
+	Long: `Dial an endpoint, complete the websocket handshake and report the latency.
+With --heartbeat a heartbeat message is also sent and the check waits for any reply.
+Exits with 0 when every checked endpoint is reachable and 1 otherwise.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeEndpoints,
+	Run:               runPing,
+}
+
+// pingResult 一个端点的检查结果
+type pingResult struct {
+	name    string
+	url     string
+	latency time.Duration
+	err     error
+}
+
+func init() {
+	pingCmd.Flags().StringArrayVarP(&pingConfigFiles, "config", "c", nil, "config file path (repeatable)")
+	pingCmd.Flags().BoolVar(&pingAll, "all", false, "check every endpoint in the config and print a summary table")
+	pingCmd.Flags().DurationVar(&pingTimeout, "timeout", 5*time.Second, "time allowed for each check")
+	pingCmd.Flags().BoolVar(&pingHeartbeat, "heartbeat", false, "send a heartbeat after connecting and wait for any reply")
+	pingCmd.Flags().BoolVar(&pingInsecure, "insecure", false, "skip TLS certificate verification for wss:// connections")
+
+	rootCmd.AddCommand(pingCmd)
+}
+
+func runPing(cmd *cobra.Command, args []string) {
+	if pingTimeout <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: --timeout must be positive")
+		os.Exit(exitConfigError)
+	}
+	if pingAll == (len(args) > 0) {
+		fmt.Fprintln(os.Stderr, "Error: specify an endpoint or --all")
+		os.Exit(exitConfigError)
+	}
+
+	// 直接给出 URL 时不需要配置文件
+	if len(args) > 0 && wshutils.IsURL(args[0]) {
+		result := pingEndpoint(args[0], args[0], wshutils.ConnectionOptions{})
+		os.Exit(printPingResult(result))
+	}
+
+	config, err := loadConfig(resolveConfigPaths(pingConfigFiles))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	if !pingAll {
+		endpoint, err := wshutils.ResolveEndpoint(config, args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		if err := endpoint.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+		result := pingEndpoint(endpoint.Name, endpoint.URL, endpoint.ConnectionOptions(wshutils.ConnectionOptions{}))
+		os.Exit(printPingResult(result))
+	}
+
+	if len(config.Endpoints) == 0 {
+		fmt.Println("No endpoints configured")
+		return
+	}
+	var results []pingResult
+	for i := range config.Endpoints {
+		endpoint := &config.Endpoints[i]
+		// 写错的端点记为失败，不影响检查其他端点
+		if err := endpoint.Validate(); err != nil {
+			results = append(results, pingResult{name: endpoint.Name, url: endpoint.URL, err: err})
+			continue
+		}
+		results = append(results, pingEndpoint(endpoint.Name, endpoint.URL, endpoint.ConnectionOptions(wshutils.ConnectionOptions{})))
+	}
+	os.Exit(printPingSummary(results))
+}
+
+// pingEndpoint 建立连接，--heartbeat 时再等待服务端的任意回应，返回所用时间
+func pingEndpoint(name, targetURL string, opts wshutils.ConnectionOptions) pingResult {
+	opts.Quiet = true
+	opts.Insecure = pingInsecure
+	opts.ConnectTimeout = pingTimeout
+
+	result := pingResult{name: name, url: targetURL}
+	start := time.Now()
+	conn, err := wshutils.NewConnectionWithOptions(targetURL, opts)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	defer conn.Close()
+	result.url = conn.URL()
+
+	if pingHeartbeat {
+		conn.SetReadDeadline(start.Add(pingTimeout))
+		if err := conn.SendJSON(wshutils.HeartbeatMsg{Type: "heartbeat", Data: ""}); err != nil {
+			result.err = fmt.Errorf("failed to send heartbeat: %v", err)
+			return result
+		}
+		if _, _, err := conn.ReadMessage(); err != nil {
+			result.err = fmt.Errorf("no reply to heartbeat: %v", err)
+			return result
+		}
+	}
+	result.latency = time.Since(start)
+	return result
+}
+
+// printPingResult 输出单个端点的检查结果，返回退出码
+func printPingResult(result pingResult) int {
+	if result.err != nil {
+		fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", result.name, result.err)
+		return exitFailure
+	}
+	fmt.Printf("OK %s (%s) %s\n", result.name, result.url, result.latency.Round(time.Millisecond))
+	return exitOK
+}
+
+// printPingSummary 输出 --all 的结果表，有端点不可达时返回 exitFailure
+func printPingSummary(results []pingResult) int {
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tURL\tSTATUS\tLATENCY")
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+			fmt.Fprintf(w, "%s\t%s\tFAIL\t%v\n", result.name, result.url, result.err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\tOK\t%s\n", result.name, result.url, result.latency.Round(time.Millisecond))
+	}
+	w.Flush()
+
+	fmt.Printf("%d/%d endpoint(s) reachable\n", len(results)-failed, len(results))
+	if failed > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// pipeDrainIdle stdin 结束后，远端输出空闲超过该时间即认为输出已经结束
+const pipeDrainIdle = time.Second
+
+// runPiped 不切换 raw 模式，由 forward 转发 stdin，远端输出写到 stdout，stdin 结束并等到输出空闲后退出
+func runPiped(conn *wshutils.Connection, stdout io.Writer, binaryOut *os.File, transcript io.Writer, forward func(conn *wshutils.Connection)) int {
+	defer conn.CloseGracefully()
+
+	output := stdout
+	if transcript != nil {
+		output = io.MultiWriter(stdout, transcript)
+	}
+
+	// 最近一次收到输出的时间（UnixNano）
+	var lastOutput atomic.Int64
+	lastOutput.Store(time.Now().UnixNano())
+
+	// 接收服务端输出，连接断开时通过 exited 返回退出码
+	exited := make(chan int, 1)
+	go func() {
+		for {
+			messageType, msg, err := conn.Read()
+			if err != nil {
+				if conn.IsClosed() {
+					return
+				}
+				exitCode := exitOK
+				if reason := wshutils.DisconnectReasonOf(err); reason != wshutils.DisconnectRemoteExit {
+					logrus.WithError(err).Warnf("Disconnected: %s", reason)
+					exitCode = exitDisconnected
+				}
+				exited <- exitCode
+				return
+			}
+			audit.Observe(msg)
+			lastOutput.Store(time.Now().UnixNano())
+			if stats := conn.Stats(); byteLimitExceeded(stats) {
+				printByteLimitExceeded(stats)
+				exited <- exitByteLimit
+				return
+			}
+			if messageType == websocket.TextMessage && conn.DispatchMessage(msg) {
+				continue
+			}
+			if messageType == websocket.BinaryMessage && binaryOut != nil {
+				binaryOut.Write(msg)
+				continue
+			}
+			output.Write(msg)
+		}
+	}()
+
+	// 转发 stdin，结束后关闭 stdinDone
+	stdinDone := make(chan struct{})
+	go func() {
+		defer close(stdinDone)
+		forward(conn)
+	}()
+
+	select {
+	case exitCode := <-exited:
+		return exitCode
+	case <-stdinDone:
+	}
+
+	// stdin 已结束，等待剩余输出
+	logrus.Info("Stdin closed, waiting for remaining output")
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case exitCode := <-exited:
+			return exitCode
+		case <-ticker.C:
+			if time.Since(time.Unix(0, lastOutput.Load())) >= pipeDrainIdle {
+				return 0
+			}
+		}
+	}
+}
+
+// forwardChunks stdin 不是终端时使用，每次读到的数据作为一条 cmd 消息
+func forwardChunks(conn *wshutils.Connection) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			audit.Record(buf[:n])
+			if sendErr := conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: string(buf[:n])}); sendErr != nil {
+				logrus.WithError(sendErr).Warn("Failed to forward stdin")
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				logrus.WithError(err).Warn("Failed to read stdin")
+			}
+			return
+		}
+	}
+}
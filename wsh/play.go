@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	playSpeed   string
+	playSince   string
+	playMaxIdle time.Duration
+)
+
+var playCmd = &cobra.Command{
+	Use:     "play <file.cast>",
+	Aliases: []string{"replay"},
+	Short:   "Play back an asciinema v2 recording in the terminal",
+	Args:    cobra.ExactArgs(1),
+	Run:     runPlay,
+}
+
+func init() {
+	playCmd.Flags().StringVar(&playSpeed, "speed", "1x", "playback speed multiplier, e.g. 2x or 0.5")
+	playCmd.Flags().StringVar(&playSince, "since", "", "start playback at this offset, e.g. 00:30, 1:02:03 or 90s")
+	playCmd.Flags().DurationVar(&playMaxIdle, "max-idle", 0, "shorten pauses longer than this, e.g. 2s (0 keeps original timing)")
+
+	rootCmd.AddCommand(playCmd)
+}
+
+func runPlay(cmd *cobra.Command, args []string) {
+	speed, err := parseSpeed(playSpeed)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var since time.Duration
+	if playSince != "" {
+		if since, err = parseOffset(playSince); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to open recording: %v\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	reader, err := wshutils.NewCastReader(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := playCast(reader, os.Stdout, speed, since, playMaxIdle); err != nil {
+		fmt.Fprintf(os.Stderr, "\nError: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// playCast 按原始时间间隔输出录像，since 之前的输出立即写出以还原屏幕内容
+// maxIdle 大于0时，超过 maxIdle 的停顿缩短为 maxIdle（按原始时间计算，再应用倍速）
+func playCast(reader *wshutils.CastReader, out io.Writer, speed float64, since time.Duration, maxIdle time.Duration) error {
+	start := since.Seconds()
+	last := start
+
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if event.Type != "o" {
+			continue
+		}
+
+		if event.Time > start {
+			gap := event.Time - last
+			if maxIdle > 0 && gap > maxIdle.Seconds() {
+				gap = maxIdle.Seconds()
+			}
+			delay := gap / speed
+			time.Sleep(time.Duration(delay * float64(time.Second)))
+			last = event.Time
+		}
+		io.WriteString(out, event.Data)
+	}
+}
+
+// parseSpeed 解析播放倍速，支持 "2x" 和 "2" 两种写法
+func parseSpeed(s string) (float64, error) {
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(s), "x"), 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("invalid speed '%s', expected a positive multiplier like 2x", s)
+	}
+	return speed, nil
+}
+
+// parseOffset 解析播放起点，支持 [hh:]mm:ss、Go duration 和秒数
+func parseOffset(s string) (time.Duration, error) {
+	if strings.Contains(s, ":") {
+		var seconds float64
+		for _, part := range strings.Split(s, ":") {
+			value, err := strconv.ParseFloat(part, 64)
+			if err != nil || value < 0 {
+				return 0, fmt.Errorf("invalid offset '%s'", s)
+			}
+			seconds = seconds*60 + value
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset '%s'", s)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
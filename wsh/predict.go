@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// 远端程序进入和离开备用屏幕（vim、less 等全屏程序）的控制序列
+var (
+	altScreenEnter = []byte("\033[?1049h")
+	altScreenLeave = []byte("\033[?1049l")
+)
+
+// localEcho --predict 模式下立即在本地回显输入的可打印字符，服务端输出到达时先把光标移回预测的位置，
+// 由服务端的真实回显覆盖；远端处于备用屏幕时不做预测
+type localEcho struct {
+	mutex sync.Mutex
+	out   io.Writer
+	// pending 已在本地显示、尚未被服务端输出覆盖的字符数
+	pending   int
+	altScreen bool
+}
+
+// newLocalEcho 创建写入 out 的本地回显
+func newLocalEcho(out io.Writer) *localEcho {
+	return &localEcho{out: out}
+}
+
+// Predict 用户输入全部是可打印 ASCII 时立即回显，包含控制字符或转义序列的输入交给服务端处理
+func (e *localEcho) Predict(input []byte) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.altScreen || len(input) == 0 {
+		return
+	}
+	for _, b := range input {
+		if b < 0x20 || b > 0x7e {
+			return
+		}
+	}
+	e.out.Write(input)
+	e.pending += len(input)
+}
+
+// Write 输出服务端数据，之前有预测的字符时先把光标左移相应的列数
+func (e *localEcho) Write(p []byte) (int, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.pending > 0 {
+		fmt.Fprintf(e.out, "\033[%dD", e.pending)
+		e.pending = 0
+	}
+	// 以最后出现的切换序列为准
+	enter := bytes.LastIndex(p, altScreenEnter)
+	leave := bytes.LastIndex(p, altScreenLeave)
+	if enter >= 0 || leave >= 0 {
+		e.altScreen = enter > leave
+	}
+	return e.out.Write(p)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+)
+
+// prefixColors 前缀标签使用的前景色，按标签选择，同一端点每次颜色相同
+var prefixColors = []int{31, 32, 33, 34, 35, 36}
+
+// prefixWriter 在每行输出前加上端点标签，用于 --no-raw 模式下把多个会话的输出 tee 到一起
+// 只在换行之后插入，行内的控制序列原样保留；不完整的行立即写出，提示符不会被拖到下一行才显示
+type prefixWriter struct {
+	out    io.Writer
+	prefix []byte
+	// atLineStart 下一个字节是新一行的开头
+	atLineStart bool
+}
+
+// newPrefixWriter 创建加上 "[label] " 前缀的 writer，color 为 true 时标签带颜色
+// 颜色只重置前景色（39），远端设置的背景色和样式不受影响
+func newPrefixWriter(out io.Writer, label string, color bool) *prefixWriter {
+	prefix := fmt.Sprintf("[%s] ", label)
+	if color {
+		h := fnv.New32a()
+		h.Write([]byte(label))
+		prefix = fmt.Sprintf("\033[%dm[%s]\033[39m ", prefixColors[h.Sum32()%uint32(len(prefixColors))], label)
+	}
+	return &prefixWriter{out: out, prefix: []byte(prefix), atLineStart: true}
+}
+
+// Write 插入前缀后一次写出，多个进程写同一输出时每条消息不会被拆开
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	buf := make([]byte, 0, len(p)+len(w.prefix))
+	for _, b := range p {
+		if w.atLineStart {
+			buf = append(buf, w.prefix...)
+			w.atLineStart = false
+		}
+		buf = append(buf, b)
+		if b == '\n' {
+			w.atLineStart = true
+		}
+	}
+	if _, err := w.out.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/spf13/cobra"
+)
+
+func TestApplyProfileMarksFlagsChanged(t *testing.T) {
+	cmd := &cobra.Command{}
+	var interval, retries int
+	cmd.Flags().IntVar(&interval, "heartbeat-interval", 15, "")
+	cmd.Flags().IntVar(&retries, "reconnect-max-retries", 10, "")
+	if err := cmd.Flags().Set("reconnect-max-retries", "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	// JSON 配置中的数字是 float64
+	profile := wshutils.Profile{"endpoint": "prod", "heartbeat-interval": float64(1000000), "reconnect-max-retries": float64(7)}
+	if err := applyProfile(cmd, profile); err != nil {
+		t.Fatalf("applyProfile: %v", err)
+	}
+	if interval != 1000000 {
+		t.Errorf("heartbeat-interval = %d, want 1000000", interval)
+	}
+	// 配置集设置的参数与命令行参数一样优先于端点的 heartbeat_interval
+	if !cmd.Flags().Changed("heartbeat-interval") {
+		t.Error("heartbeat-interval set by the profile is not marked as changed")
+	}
+	// 命令行显式指定的参数不被配置集覆盖
+	if retries != 3 {
+		t.Errorf("reconnect-max-retries = %d, want the command-line value 3", retries)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 退出时模仿 reset 命令发送的终端控制序列
+const (
+	// seqClearScreen 清除屏幕，部分终端会因此丢失屏幕上的会话输出
+	seqClearScreen = "\033[2J"
+	// seqCursorHome 移动光标到第一行第一列
+	seqCursorHome = "\033[H"
+	// seqResetAttributes 重置所有属性（颜色、样式等）
+	seqResetAttributes = "\033[0m"
+	// seqResetCursorShape 重置光标形状
+	seqResetCursorShape = "\033[0 q"
+	// seqShowCursor 显示光标
+	seqShowCursor = "\033[?25h"
+	// seqNormalCursorKeys 方向键恢复为普通模式
+	seqNormalCursorKeys = "\033[?1l"
+	// seqAutoWrap 开启自动换行
+	seqAutoWrap = "\033[?7h"
+	// seqBracketedPasteOff 关闭远端 shell 开启的 bracketed paste 模式
+	seqBracketedPasteOff = "\033[?2004l"
+)
+
+// resetSequence resetTerminal 按顺序输出的控制序列，如需保留屏幕内容可以去掉 seqClearScreen 和 seqCursorHome
+var resetSequence = []string{
+	seqClearScreen,
+	seqCursorHome,
+	seqResetAttributes,
+	seqResetCursorShape,
+	seqShowCursor,
+	seqNormalCursorKeys,
+	seqAutoWrap,
+	seqBracketedPasteOff,
+}
+
+// resetTerminal 退出时重置终端，清除远端程序留下的屏幕内容和终端模式，--no-reset 时不调用
+func resetTerminal() {
+	for _, seq := range resetSequence {
+		fmt.Print(seq)
+	}
+
+	logrus.Debug("Terminal reset completed")
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// sendFileTimeout 数据发送完成后等待远端写完文件的时间
+const sendFileTimeout = 30 * time.Second
+
+// fileUpload 交互会话中按 --send-file-key 上传文件
+// 输入路径时暂存远端输出，传输期间丢弃远端回显的编码数据和 heredoc 提示，直到识别到结束标记
+type fileUpload struct {
+	mu sync.Mutex
+	// out 恢复显示时写入暂存输出的会话输出
+	out     io.Writer
+	holding bool
+	held    []byte
+	// sentinel 传输进行中时识别结束标记，status 接收远端命令的退出码
+	sentinel *commandSentinel
+	status   chan int
+}
+
+// newFileUpload 创建上传器，暂存的输出最后写入 out
+func newFileUpload(out io.Writer) *fileUpload {
+	return &fileUpload{out: out}
+}
+
+// Filter 接收协程收到远端输出时调用，返回 true 表示输出已被暂存或丢弃
+func (u *fileUpload) Filter(msg []byte) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if !u.holding {
+		return false
+	}
+	if u.sentinel == nil {
+		u.held = append(u.held, msg...)
+		return true
+	}
+	if _, status, done := u.sentinel.Feed(msg); done {
+		// 标记行之后是远端的新提示符，恢复显示时输出
+		u.held = append(u.held, u.sentinel.Flush()...)
+		u.sentinel = nil
+		u.status <- status
+	}
+	return true
+}
+
+// hold 开始暂存远端输出
+func (u *fileUpload) hold() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.holding = true
+}
+
+// mute 开始丢弃远端输出，直到识别到 sentinel 的结束标记
+func (u *fileUpload) mute(sentinel *commandSentinel) <-chan int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.sentinel = sentinel
+	u.status = make(chan int, 1)
+	return u.status
+}
+
+// release 停止暂存，写出暂存的输出
+func (u *fileUpload) release() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.holding = false
+	u.sentinel = nil
+	u.out.Write(u.held)
+	u.held = nil
+}
+
+// Run 在临时的提示行上读取本地文件和远端路径，通过同一连接发送文件，完成后回到交互模式
+// cooked 是进入 raw 模式之前的终端状态，读取路径时临时恢复，以便使用本地行编辑
+func (u *fileUpload) Run(conn *wshutils.Connection, cooked *term.State) {
+	u.hold()
+	defer u.release()
+
+	localPath, remotePath, ok := promptUpload(cooked)
+	if !ok {
+		fmt.Fprint(os.Stderr, "[wsh] send canceled\r\n")
+		return
+	}
+
+	sentinel, err := newCommandSentinel()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[wsh] send failed: %v\r\n", err)
+		return
+	}
+	status := u.mute(sentinel)
+
+	logrus.Infof("Sending file %s to %s", localPath, remotePath)
+	// 关闭远端回显，编码数据不会回显到终端
+	conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: "stty -echo 2>/dev/null\n"})
+	lastPercent := -1
+	opts := wshutils.TransferOptions{Progress: func(sent, total int) {
+		// 百分比变化时才重画进度
+		if total > 0 && sent*100/total != lastPercent {
+			lastPercent = sent * 100 / total
+			fmt.Fprintf(os.Stderr, "\r\033[K[wsh] sending %s: %d%%", filepath.Base(localPath), lastPercent)
+		}
+	}}
+	if err := wshutils.SendFile(conn, localPath, remotePath, opts); err != nil {
+		conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: "stty echo 2>/dev/null\n"})
+		logrus.WithError(err).Warn("Failed to send file")
+		fmt.Fprintf(os.Stderr, "\r\033[K[wsh] send failed: %v\r\n", err)
+		return
+	}
+	// 标记行报告 heredoc 管道的退出码，之后恢复回显
+	conn.SendJSON(wshutils.CmdMsg{Type: "cmd", Cmd: sentinel.Command() + "; stty echo 2>/dev/null\n"})
+
+	select {
+	case code := <-status:
+		if code != 0 {
+			fmt.Fprintf(os.Stderr, "\r\033[K[wsh] send failed: writing %s on the remote exited with status %d\r\n", remotePath, code)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "\r\033[K[wsh] sent %s to %s\r\n", localPath, remotePath)
+	case <-time.After(sendFileTimeout):
+		fmt.Fprintf(os.Stderr, "\r\033[K[wsh] no confirmation from the remote after %v, %s may be incomplete\r\n", sendFileTimeout, remotePath)
+	case <-conn.Done():
+	}
+}
+
+// promptUpload 读取要发送的本地文件和远端路径，远端路径默认为文件名，相对于远端 shell 的当前目录
+func promptUpload(cooked *term.State) (localPath, remotePath string, ok bool) {
+	fd := int(os.Stdin.Fd())
+	raw, err := term.GetState(fd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\r\n[wsh] send failed: %v\r\n", err)
+		return "", "", false
+	}
+	term.Restore(fd, cooked)
+	defer term.Restore(fd, raw)
+
+	fmt.Fprint(os.Stderr, "\r\n[wsh] Local file to send (empty to cancel): ")
+	line, err := wshutils.ReadLine(os.Stdin)
+	localPath = strings.TrimSpace(line)
+	if err != nil || localPath == "" {
+		return "", "", false
+	}
+	if rest, found := strings.CutPrefix(localPath, "~/"); found {
+		if home, err := os.UserHomeDir(); err == nil {
+			localPath = filepath.Join(home, rest)
+		}
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[wsh] %v\n", err)
+		return "", "", false
+	}
+	if info.IsDir() {
+		fmt.Fprintf(os.Stderr, "[wsh] %s is a directory, use wcp --recursive to send directories\n", localPath)
+		return "", "", false
+	}
+
+	fmt.Fprintf(os.Stderr, "[wsh] Remote path [%s]: ", filepath.Base(localPath))
+	line, err = wshutils.ReadLine(os.Stdin)
+	if err != nil {
+		return "", "", false
+	}
+	remotePath = strings.TrimSpace(line)
+	if remotePath == "" {
+		remotePath = filepath.Base(localPath)
+	}
+	return localPath, remotePath, true
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gitchs/wsh/wshutils"
+)
+
+// latencyProbeInterval --show-latency 模式下发送 ping 测量往返时间的间隔
+const latencyProbeInterval = 5 * time.Second
+
+// formatStatus 按下状态键时显示的状态行：最近一次往返时间，以及 ping 迟迟没有回应时等待的时长
+func formatStatus(conn *wshutils.Connection, now time.Time) string {
+	latency := "unknown"
+	if rtt := conn.Latency(); rtt > 0 {
+		latency = rtt.Round(10 * time.Microsecond).String()
+	}
+	status := fmt.Sprintf("[wsh] latency %s", latency)
+
+	lastPing, lastPong := conn.LastPing(), conn.LastPong()
+	if !lastPong.IsZero() {
+		status += fmt.Sprintf(", last pong %s ago", now.Sub(lastPong).Round(time.Second))
+	}
+	// 最近的 ping 超过一秒没有回应时提示，链路变差时往返时间可能还没有更新
+	if !lastPing.IsZero() && lastPing.After(lastPong) && now.Sub(lastPing) > time.Second {
+		status += fmt.Sprintf(", waiting %s for pong", now.Sub(lastPing).Round(time.Second))
+	}
+
+	stats := conn.Stats()
+	return status + fmt.Sprintf(", %d bytes sent, %d received", stats.BytesSent, stats.BytesReceived)
+}
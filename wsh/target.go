@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gitchs/wsh/wshutils"
+	"golang.org/x/term"
+)
+
+// targetEnv 未给出端点参数时读取目标的环境变量，避免带 token 的 URL 出现在 ps 和 shell 历史中
+const targetEnv = "WSH_URL"
+
+// readTarget 参数为 "-" 时从 stdin 读取一行作为目标，终端上读取时不回显
+func readTarget(in *os.File) (string, error) {
+	var line string
+	if term.IsTerminal(int(in.Fd())) {
+		fmt.Fprint(os.Stderr, "Endpoint or URL: ")
+		data, err := term.ReadPassword(int(in.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read target from stdin: %v", err)
+		}
+		line = string(data)
+	} else {
+		var err error
+		if line, err = wshutils.ReadLine(in); err != nil {
+			return "", fmt.Errorf("failed to read target from stdin: %v", err)
+		}
+	}
+
+	target := strings.TrimSpace(line)
+	if target == "" {
+		return "", fmt.Errorf("no target read from stdin")
+	}
+	return target, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// 窗口标题相关的终端控制序列
+const (
+	// seqPushTitle 保存当前窗口标题，xterm 兼容终端支持
+	seqPushTitle = "\033[22;0t"
+	// seqPopTitle 恢复保存的窗口标题
+	seqPopTitle = "\033[23;0t"
+)
+
+// windowTitle 交互会话的窗口标题，--title 给出时原样使用，否则为 "wsh: 端点名"
+func windowTitle(override, name string) string {
+	if override != "" {
+		return override
+	}
+	return "wsh: " + name
+}
+
+// setWindowTitle 保存原标题后设置窗口标题，控制字符会被去掉，避免提前结束 OSC 序列
+func setWindowTitle(out io.Writer, title string) {
+	title = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, title)
+	fmt.Fprintf(out, "%s\033]0;%s\007", seqPushTitle, title)
+}
+
+// resetWindowTitle 清空标题并恢复保存的原标题，不支持恢复的终端至少不会留下端点名
+func resetWindowTitle(out io.Writer) {
+	fmt.Fprintf(out, "\033]0;\007%s", seqPopTitle)
+}
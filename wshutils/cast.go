@@ -0,0 +1,160 @@
+package wshutils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// castMaxLineSize 单条事件的最大长度
+const castMaxLineSize = 16 * 1024 * 1024
+
+// CastHeader asciinema v2 文件头
+type CastHeader struct {
+	Version   int   `json:"version"`
+	Width     int   `json:"width"`
+	Height    int   `json:"height"`
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
+
+// CastEvent asciinema v2 事件，Time 为距会话开始的秒数，Type 为 "o"（输出）或 "i"（输入）
+type CastEvent struct {
+	Time float64
+	Type string
+	Data string
+}
+
+// CastReader 逐条读取 asciinema v2 录像
+type CastReader struct {
+	Header  CastHeader
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewCastReader 创建录像读取器并解析文件头
+func NewCastReader(r io.Reader) (*CastReader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), castMaxLineSize)
+
+	reader := &CastReader{scanner: scanner}
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read cast header: %v", err)
+		}
+		return nil, fmt.Errorf("empty cast file")
+	}
+	reader.line++
+	if err := json.Unmarshal(scanner.Bytes(), &reader.Header); err != nil {
+		return nil, fmt.Errorf("invalid cast header: %v", err)
+	}
+	if reader.Header.Version != 2 {
+		return nil, fmt.Errorf("unsupported cast version %d, only version 2 is supported", reader.Header.Version)
+	}
+
+	return reader, nil
+}
+
+// Next 读取下一条事件，读完时返回 io.EOF
+func (reader *CastReader) Next() (*CastEvent, error) {
+	for reader.scanner.Scan() {
+		reader.line++
+		line := reader.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var fields []json.RawMessage
+		if err := json.Unmarshal(line, &fields); err != nil || len(fields) != 3 {
+			return nil, fmt.Errorf("invalid cast event at line %d", reader.line)
+		}
+		var event CastEvent
+		if err := json.Unmarshal(fields[0], &event.Time); err != nil {
+			return nil, fmt.Errorf("invalid event time at line %d: %v", reader.line, err)
+		}
+		if err := json.Unmarshal(fields[1], &event.Type); err != nil {
+			return nil, fmt.Errorf("invalid event type at line %d: %v", reader.line, err)
+		}
+		if err := json.Unmarshal(fields[2], &event.Data); err != nil {
+			return nil, fmt.Errorf("invalid event data at line %d: %v", reader.line, err)
+		}
+		return &event, nil
+	}
+
+	if err := reader.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// CastWriter 以 asciinema v2 格式记录会话输出，每条事件直接写入底层 Writer
+type CastWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	// pending 上一块数据末尾不完整的 UTF-8 字符，与下一块拼接后再写入
+	pending []byte
+}
+
+// NewCastWriter 写入文件头并创建录像写入器，事件时间从此刻开始计算
+func NewCastWriter(w io.Writer, width int, height int) (*CastWriter, error) {
+	start := time.Now()
+	header, err := json.Marshal(CastHeader{Version: 2, Width: width, Height: height, Timestamp: start.Unix()})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(header, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to write cast header: %v", err)
+	}
+	return &CastWriter{w: w, start: start}, nil
+}
+
+// Write 将 p 记录为一条输出事件，实现 io.Writer
+func (cw *CastWriter) Write(p []byte) (int, error) {
+	if err := cw.WriteEvent("o", p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteEvent 记录一条指定类型的事件
+func (cw *CastWriter) WriteEvent(eventType string, data []byte) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	data = append(cw.pending, data...)
+	cw.pending = nil
+	// 数据块可能在多字节字符中间截断，留到下一块再写
+	if cut := incompleteRuneStart(data); cut < len(data) {
+		cw.pending = append([]byte(nil), data[cut:]...)
+		data = data[:cut]
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	elapsed := time.Since(cw.start).Seconds()
+	line, err := json.Marshal([]interface{}{elapsed, eventType, string(data)})
+	if err != nil {
+		return err
+	}
+	_, err = cw.w.Write(append(line, '\n'))
+	return err
+}
+
+// incompleteRuneStart 返回末尾不完整 UTF-8 字符的起始位置，没有时返回 len(data)
+func incompleteRuneStart(data []byte) int {
+	// UTF-8 字符最长4字节，只需检查最后3个字节
+	for i := len(data) - 1; i >= 0 && i >= len(data)-3; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
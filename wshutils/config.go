@@ -0,0 +1,530 @@
+package wshutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+type Endpoint struct {
+	Name        string `yaml:"name" json:"name"`
+	URL         string `yaml:"url" json:"url"`
+	Description string `yaml:"description" json:"description"`
+	// URLs url 连接失败时依次尝试的其他地址，如同一服务的多个网关；只设置 urls 时第一个作为 url
+	URLs []string `yaml:"urls" json:"urls"`
+	// Confirm 连接前要求输入端点名称确认，用于保护生产环境
+	Confirm bool `yaml:"confirm" json:"confirm"`
+	// CAFile 验证服务端证书使用的CA证书文件（PEM）
+	CAFile string `yaml:"ca_file" json:"ca_file"`
+	// Headers 握手请求附带的HTTP头，如 Authorization: Bearer ${API_TOKEN}
+	Headers map[string]string `yaml:"headers" json:"headers"`
+	// HeartbeatInterval 该端点的心跳间隔（秒），覆盖默认的 --heartbeat-interval，0 表示未设置
+	HeartbeatInterval int `yaml:"heartbeat_interval" json:"heartbeat_interval"`
+	// Query 握手 URL 附带的查询参数，覆盖 URL 中的同名参数，如 token: ${GATEWAY_TOKEN}
+	Query map[string]string `yaml:"query" json:"query"`
+	// OnConnect 连接建立后依次发送的命令，如 cd /srv；设置后代替全局的 on_connect
+	OnConnect []string `yaml:"on_connect" json:"on_connect"`
+	// Relay 设置后 url 是跳板中继的地址，连接后请求中继转接到 relay.target
+	Relay *Relay `yaml:"relay" json:"relay"`
+	// Subprotocols 握手时请求的子协议，如 [shell.v1]
+	Subprotocols []string `yaml:"subprotocols" json:"subprotocols"`
+	// InitMessage 连接建立后、设置 TERM 之前原样发送的第一条消息，如 {"type":"auth","token":"${API_TOKEN}"}
+	InitMessage string `yaml:"init_message" json:"init_message"`
+	// PostCommands wcp 传输完成后在远端执行的命令，代替默认的 reset 和 echo 'it works'；设置为 [] 时不执行任何命令
+	PostCommands []string `yaml:"post_commands" json:"post_commands"`
+	// Tags 端点的分组标签，如 [prod, web]，可用 wsh list --tag 过滤，唯一匹配时可以代替名称连接
+	Tags []string `yaml:"tags" json:"tags"`
+}
+
+type Config struct {
+	Endpoints []Endpoint         `yaml:"endpoints" json:"endpoints"`
+	Profiles  map[string]Profile `yaml:"profiles" json:"profiles"`
+	// Default 不带参数运行 wsh 时连接的端点名称
+	Default string `yaml:"default" json:"default"`
+	// StrictEnv 端点 URL 或描述引用了未设置的环境变量时报错，否则展开为空字符串
+	StrictEnv bool `yaml:"strict_env" json:"strict_env"`
+	// OnConnect 没有配置 on_connect 的端点连接后默认发送的命令
+	OnConnect []string `yaml:"on_connect" json:"on_connect"`
+}
+
+// Profile 命名的连接配置集，endpoint 指定目标，其余键按命令行参数名解释
+type Profile map[string]interface{}
+
+// SystemConfigPath 系统级配置文件，用户配置不存在时使用
+const SystemConfigPath = "/etc/wsh/config.yaml"
+
+// UserConfigDir 用户配置目录：$XDG_CONFIG_HOME（必须是绝对路径），未设置时为 ~/.config
+func UserConfigDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".config")
+}
+
+// ConfigSearchPath 按优先级列出查找的配置文件：用户配置目录下的 wsh.yaml，然后是 SystemConfigPath
+func ConfigSearchPath() []string {
+	var paths []string
+	if dir := UserConfigDir(); dir != "" {
+		paths = append(paths, filepath.Join(dir, "wsh.yaml"))
+	}
+	return append(paths, SystemConfigPath)
+}
+
+// GetDefaultConfigPath 获取默认配置文件路径：ConfigSearchPath 中第一个存在的文件，都不存在时返回用户配置文件路径
+func GetDefaultConfigPath() string {
+	paths := ConfigSearchPath()
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	if UserConfigDir() == "" {
+		return "config.yaml" // fallback to local config.yaml
+	}
+	return paths[0]
+}
+
+// GetDefaultConfigPaths 默认配置文件列表：默认配置文件和用户配置目录下 wsh.d 中按文件名排序的 *.yaml、*.json
+func GetDefaultConfigPaths() []string {
+	defaultPath := GetDefaultConfigPath()
+	extraDir := filepath.Join(UserConfigDir(), "wsh.d")
+	extra, _ := filepath.Glob(filepath.Join(extraDir, "*.yaml"))
+	jsonFiles, _ := filepath.Glob(filepath.Join(extraDir, "*.json"))
+	extra = append(extra, jsonFiles...)
+	sort.Strings(extra)
+
+	// 只有 wsh.d 中有配置时才允许 wsh.yaml 不存在，否则保留原来的"读取失败"报错
+	if _, err := os.Stat(defaultPath); err != nil && len(extra) > 0 {
+		return extra
+	}
+	return append([]string{defaultPath}, extra...)
+}
+
+// LoadConfigs 依次加载并合并多个配置文件，同名端点由后面的文件覆盖，返回重复定义的警告
+func LoadConfigs(configPaths []string) (*Config, []string, error) {
+	merged := &Config{}
+	// 端点名称到 merged.Endpoints 下标和来源文件的映射
+	index := make(map[string]int)
+	source := make(map[string]string)
+	var warnings []string
+
+	for _, configPath := range configPaths {
+		config, err := LoadConfig(configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// 端点保持首次出现的位置，保证合并结果的顺序固定
+		for _, endpoint := range config.Endpoints {
+			if i, ok := index[endpoint.Name]; ok {
+				if source[endpoint.Name] == configPath {
+					warnings = append(warnings, fmt.Sprintf("endpoint '%s' is defined more than once in %s, the last definition is used", endpoint.Name, configPath))
+				} else {
+					warnings = append(warnings, fmt.Sprintf("endpoint '%s' in %s overrides the definition in %s", endpoint.Name, configPath, source[endpoint.Name]))
+				}
+				merged.Endpoints[i] = endpoint
+			} else {
+				index[endpoint.Name] = len(merged.Endpoints)
+				merged.Endpoints = append(merged.Endpoints, endpoint)
+			}
+			source[endpoint.Name] = configPath
+		}
+
+		for name, profile := range config.Profiles {
+			if merged.Profiles == nil {
+				merged.Profiles = make(map[string]Profile)
+			}
+			merged.Profiles[name] = profile
+		}
+		if config.Default != "" {
+			merged.Default = config.Default
+		}
+		merged.StrictEnv = merged.StrictEnv || config.StrictEnv
+		if len(config.OnConnect) > 0 {
+			merged.OnConnect = config.OnConnect
+		}
+	}
+
+	return merged, warnings, nil
+}
+
+// LoadConfig 加载配置文件，支持 YAML 和 JSON 格式
+// 只解析、展开环境变量并整理空白，不检查端点是否有效，一个端点写错不影响列出和使用其他端点；连接前用 Endpoint.Validate 检查
+func LoadConfig(configPath string) (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file '%s': %v", configPath, err)
+	}
+
+	var config Config
+	unmarshal := yaml.Unmarshal
+	if isJSONConfig(configPath, data) {
+		unmarshal = json.Unmarshal
+	}
+	if err := unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file '%s': %v", configPath, err)
+	}
+
+	if err := expandEndpoints(&config); err != nil {
+		return nil, fmt.Errorf("invalid config file '%s': %v", configPath, err)
+	}
+	normalizeEndpoints(&config)
+
+	return &config, nil
+}
+
+// isJSONConfig 按扩展名判断配置格式，.yaml/.yml 之外的未知扩展名时看第一个非空白字符是否为 '{'
+func isJSONConfig(configPath string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".json":
+		return true
+	case ".yaml", ".yml":
+		return false
+	}
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// expandEndpoints 展开端点 URL 和描述中的 ${VAR} 和 $VAR 环境变量引用
+func expandEndpoints(config *Config) error {
+	for i := range config.Endpoints {
+		endpoint := &config.Endpoints[i]
+		var missing []string
+		mapping := func(name string) string {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				missing = append(missing, name)
+			}
+			return value
+		}
+
+		endpoint.URL = os.Expand(endpoint.URL, mapping)
+		for j := range endpoint.URLs {
+			endpoint.URLs[j] = os.Expand(endpoint.URLs[j], mapping)
+		}
+		endpoint.Description = os.Expand(endpoint.Description, mapping)
+		if endpoint.Relay != nil {
+			endpoint.Relay.Target = os.Expand(endpoint.Relay.Target, mapping)
+		}
+		if config.StrictEnv && len(missing) > 0 {
+			return fmt.Errorf("endpoint '%s' references unset environment variables: %s", endpoint.Name, strings.Join(missing, ", "))
+		}
+	}
+	return nil
+}
+
+// normalizeEndpoints 去掉名称、URL、标签和中继目标首尾的空白，只设置了 urls 时第一个地址移到 url
+func normalizeEndpoints(config *Config) {
+	for i := range config.Endpoints {
+		endpoint := &config.Endpoints[i]
+		endpoint.Name = strings.TrimSpace(endpoint.Name)
+		endpoint.URL = strings.TrimSpace(endpoint.URL)
+		for j := range endpoint.URLs {
+			endpoint.URLs[j] = strings.TrimSpace(endpoint.URLs[j])
+		}
+		if endpoint.URL == "" && len(endpoint.URLs) > 0 {
+			endpoint.URL, endpoint.URLs = endpoint.URLs[0], endpoint.URLs[1:]
+		}
+		for j := range endpoint.Tags {
+			endpoint.Tags[j] = strings.TrimSpace(endpoint.Tags[j])
+		}
+		if endpoint.Relay != nil {
+			endpoint.Relay.Target = strings.TrimSpace(endpoint.Relay.Target)
+		}
+	}
+}
+
+// Validate 检查所有端点，一次返回所有问题；不修改配置，同名端点不算错误（LoadConfigs 给出警告，后面的定义生效）
+func (config *Config) Validate() error {
+	var problems []string
+	for i := range config.Endpoints {
+		endpoint := &config.Endpoints[i]
+		label := fmt.Sprintf("endpoint '%s'", endpoint.Name)
+		if endpoint.Name == "" {
+			label = fmt.Sprintf("endpoint #%d", i+1)
+		}
+		for _, problem := range endpoint.problems() {
+			problems = append(problems, label+": "+problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problem(s):\n  - %s", len(problems), strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// Validate 检查端点名称非空、URL 可以解析并使用 ws://、wss:// 或 ws+unix://、心跳间隔不为负、中继指定了目标，一次返回所有问题
+func (endpoint *Endpoint) Validate() error {
+	problems := endpoint.problems()
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid endpoint '%s': %s", endpoint.Name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// problems 端点的所有问题
+func (endpoint *Endpoint) problems() []string {
+	var problems []string
+	if endpoint.Name == "" {
+		problems = append(problems, "missing name")
+	}
+	if err := validateEndpointURL(endpoint.URL); err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, fallback := range endpoint.URLs {
+		if err := validateEndpointURL(fallback); err != nil {
+			problems = append(problems, fmt.Sprintf("urls: %v", err))
+		}
+	}
+	if slices.Contains(endpoint.Tags, "") {
+		problems = append(problems, "empty tag")
+	}
+	if endpoint.HeartbeatInterval < 0 {
+		problems = append(problems, "heartbeat_interval must be a positive number of seconds")
+	}
+	if relay := endpoint.Relay; relay != nil {
+		if relay.Target == "" {
+			problems = append(problems, "relay is missing target")
+		}
+		if relay.Timeout < 0 {
+			problems = append(problems, "relay timeout must be a positive number of seconds")
+		}
+	}
+	return problems
+}
+
+// validateEndpointURL 检查端点 URL 的格式
+func validateEndpointURL(rawURL string) error {
+	if rawURL == "" {
+		return fmt.Errorf("missing url")
+	}
+	if strings.HasPrefix(rawURL, unixURLPrefix) {
+		_, _, err := splitUnixURL(rawURL)
+		return err
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return fmt.Errorf("invalid url '%s': scheme must be ws, wss or ws+unix", rawURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid url '%s': missing host", rawURL)
+	}
+	return nil
+}
+
+// FindEndpoint 根据名称查找端点
+func FindEndpoint(config *Config, name string) (*Endpoint, error) {
+	for _, endpoint := range config.Endpoints {
+		if endpoint.Name == name {
+			return &endpoint, nil
+		}
+	}
+	return nil, fmt.Errorf("endpoint '%s' not found in config", name)
+}
+
+// AmbiguousTagError 标签匹配到多个端点，无法确定要连接哪一个
+type AmbiguousTagError struct {
+	Tag   string
+	Names []string
+}
+
+func (e *AmbiguousTagError) Error() string {
+	return fmt.Sprintf("tag '%s' matches %d endpoints: %s", e.Tag, len(e.Names), strings.Join(e.Names, ", "))
+}
+
+// ResolveEndpoint 先按名称查找端点，找不到时查找带有该标签的端点，标签匹配多个端点时返回 *AmbiguousTagError
+func ResolveEndpoint(config *Config, nameOrTag string) (*Endpoint, error) {
+	endpoint, err := FindEndpoint(config, nameOrTag)
+	if err == nil {
+		return endpoint, nil
+	}
+
+	tagged := FilterEndpointsByTag(config.Endpoints, nameOrTag)
+	switch len(tagged) {
+	case 0:
+		return nil, err
+	case 1:
+		return &tagged[0], nil
+	}
+	names := make([]string, 0, len(tagged))
+	for _, endpoint := range tagged {
+		names = append(names, endpoint.Name)
+	}
+	return nil, &AmbiguousTagError{Tag: nameOrTag, Names: names}
+}
+
+// HasTag 检查端点是否带有标签 tag（区分大小写）
+func (endpoint *Endpoint) HasTag(tag string) bool {
+	return slices.Contains(endpoint.Tags, tag)
+}
+
+// ConnectionOptions 在 base 上加入连接端点所需的握手参数（CA、请求头、查询参数、中继、初始消息、子协议和备用地址）
+// base 中已经设置的 CAFile 和 Subprotocols 来自命令行，优先于端点配置
+func (endpoint *Endpoint) ConnectionOptions(base ConnectionOptions) ConnectionOptions {
+	opts := base
+	if opts.CAFile == "" {
+		opts.CAFile = endpoint.CAFile
+	}
+	if len(opts.Subprotocols) == 0 {
+		opts.Subprotocols = endpoint.Subprotocols
+	}
+	opts.Headers = endpoint.Headers
+	opts.Query = endpoint.Query
+	opts.Relay = endpoint.Relay
+	opts.InitMessage = endpoint.InitMessage
+	opts.FallbackURLs = endpoint.URLs
+	return opts
+}
+
+// FilterEndpointsByTag 返回带有标签 tag 的端点，tag 为空时返回全部
+func FilterEndpointsByTag(endpoints []Endpoint, tag string) []Endpoint {
+	if tag == "" {
+		return endpoints
+	}
+	var matches []Endpoint
+	for _, endpoint := range endpoints {
+		if endpoint.HasTag(tag) {
+			matches = append(matches, endpoint)
+		}
+	}
+	return matches
+}
+
+// SearchEndpoints 返回名称或描述包含 query 的端点（不区分大小写），query 为空时返回全部
+func SearchEndpoints(config *Config, query string) []Endpoint {
+	query = strings.ToLower(query)
+	var matches []Endpoint
+	for _, endpoint := range config.Endpoints {
+		if strings.Contains(strings.ToLower(endpoint.Name), query) ||
+			strings.Contains(strings.ToLower(endpoint.Description), query) {
+			matches = append(matches, endpoint)
+		}
+	}
+	return matches
+}
+
+// ConfirmEndpoint 对标记了 confirm 的端点，要求用户输入端点名称确认后才继续
+func ConfirmEndpoint(endpoint *Endpoint, in io.Reader, out io.Writer) error {
+	if !endpoint.Confirm {
+		return nil
+	}
+
+	// 管道中的内容是要转发的输入，不能当作确认
+	if f, ok := in.(*os.File); ok && !term.IsTerminal(int(f.Fd())) {
+		return fmt.Errorf("endpoint '%s' requires confirmation, which cannot be read while stdin is not a terminal; pass --yes", endpoint.Name)
+	}
+
+	fmt.Fprintf(out, "Connect to %s? Type the endpoint name to confirm: ", strings.ToUpper(endpoint.Name))
+	line, err := ReadLine(in)
+	if err != nil {
+		return fmt.Errorf("confirmation aborted: %v", err)
+	}
+	if strings.TrimSpace(line) != endpoint.Name {
+		return fmt.Errorf("confirmation failed: expected '%s'", endpoint.Name)
+	}
+	return nil
+}
+
+// ReadLine 逐字节读取一行，不多读，剩余的 stdin 留给会话转发
+func ReadLine(in io.Reader) (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, buf[0])
+		}
+		if err == io.EOF {
+			return string(line), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// IsURL 检查字符串是否为URL
+func IsURL(s string) bool {
+	return strings.HasPrefix(s, "ws://") || strings.HasPrefix(s, "wss://") || strings.HasPrefix(s, unixURLPrefix)
+}
+
+// FindProfile 根据名称查找连接配置集
+func FindProfile(config *Config, name string) (Profile, error) {
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile '%s' not found in config", name)
+	}
+	return profile, nil
+}
+
+// Endpoint 返回配置集指定的端点名称或URL
+func (p Profile) Endpoint() string {
+	if v, ok := p["endpoint"]; ok && v != nil {
+		return profileValue(v)
+	}
+	return ""
+}
+
+// FlagNames 返回配置集中除 endpoint 外的参数名，按字母排序
+func (p Profile) FlagNames() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		if name != "endpoint" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FlagValues 返回参数的字符串值，列表类型的值展开为多个
+func (p Profile) FlagValues(name string) []string {
+	switch v := p[name].(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, profileValue(item))
+		}
+		return values
+	default:
+		return []string{profileValue(v)}
+	}
+}
+
+// profileValue 把配置集中的值格式化为命令行参数
+// JSON 配置中的数字都是 float64，fmt.Sprint 会把 1000000 写成 1e+06，整数参数无法解析
+func profileValue(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	default:
+		return fmt.Sprint(v)
+	}
+}
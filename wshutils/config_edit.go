@@ -0,0 +1,163 @@
+package wshutils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AddEndpoint 在 YAML 配置文件的 endpoints 末尾追加端点，文件不存在时创建
+// 直接修改 YAML 节点，保留文件中的注释、其他配置和未展开的 ${VAR} 引用
+func AddEndpoint(configPath string, endpoint Endpoint) error {
+	endpoint.Name = strings.TrimSpace(endpoint.Name)
+	endpoint.URL = strings.TrimSpace(endpoint.URL)
+	if endpoint.Name == "" {
+		return fmt.Errorf("endpoint name must not be empty")
+	}
+	if err := validateEndpointURL(os.ExpandEnv(endpoint.URL)); err != nil {
+		return fmt.Errorf("endpoint '%s': %v", endpoint.Name, err)
+	}
+
+	doc, err := loadConfigNode(configPath, true)
+	if err != nil {
+		return err
+	}
+	endpoints, err := endpointsNode(doc, configPath)
+	if err != nil {
+		return err
+	}
+	if i, err := findEndpointNode(endpoints, endpoint.Name); err != nil {
+		return fmt.Errorf("failed to parse config file '%s': %v", configPath, err)
+	} else if i >= 0 {
+		return fmt.Errorf("endpoint '%s' already exists in %s", endpoint.Name, configPath)
+	}
+
+	item := &yaml.Node{Kind: yaml.MappingNode}
+	appendScalar(item, "name", endpoint.Name)
+	appendScalar(item, "url", endpoint.URL)
+	if endpoint.Description != "" {
+		appendScalar(item, "description", endpoint.Description)
+	}
+	endpoints.Content = append(endpoints.Content, item)
+
+	return saveConfigNode(configPath, doc)
+}
+
+// RemoveEndpoint 从 YAML 配置文件中删除端点，保留文件的其余内容
+func RemoveEndpoint(configPath string, name string) error {
+	name = strings.TrimSpace(name)
+	doc, err := loadConfigNode(configPath, false)
+	if err != nil {
+		return err
+	}
+	endpoints, err := endpointsNode(doc, configPath)
+	if err != nil {
+		return err
+	}
+	i, err := findEndpointNode(endpoints, name)
+	if err != nil {
+		return fmt.Errorf("failed to parse config file '%s': %v", configPath, err)
+	}
+	if i < 0 {
+		return fmt.Errorf("endpoint '%s' not found in %s", name, configPath)
+	}
+	endpoints.Content = append(endpoints.Content[:i], endpoints.Content[i+1:]...)
+
+	return saveConfigNode(configPath, doc)
+}
+
+// loadConfigNode 读取配置文件的 YAML 文档节点，create 为 true 时文件不存在或为空返回空文档
+func loadConfigNode(configPath string, create bool) (*yaml.Node, error) {
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) && create {
+		data = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read config file '%s': %v", configPath, err)
+	}
+	if isJSONConfig(configPath, data) {
+		return nil, fmt.Errorf("config file '%s' is JSON, only YAML files can be edited", configPath)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file '%s': %v", configPath, err)
+	}
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	return &doc, nil
+}
+
+// endpointsNode 返回 endpoints 列表节点，不存在时在顶层添加
+func endpointsNode(doc *yaml.Node, configPath string) (*yaml.Node, error) {
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid config file '%s': top level must be a mapping", configPath)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "endpoints" {
+			continue
+		}
+		value := root.Content[i+1]
+		// endpoints: 后面没有内容时是 null
+		if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+			*value = yaml.Node{Kind: yaml.SequenceNode}
+		}
+		if value.Kind != yaml.SequenceNode {
+			return nil, fmt.Errorf("invalid config file '%s': endpoints must be a list", configPath)
+		}
+		return value, nil
+	}
+
+	value := &yaml.Node{Kind: yaml.SequenceNode}
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "endpoints"}, value)
+	return value, nil
+}
+
+// findEndpointNode 查找名称为 name 的端点在列表中的下标，不存在时返回 -1
+func findEndpointNode(endpoints *yaml.Node, name string) (int, error) {
+	for i, item := range endpoints.Content {
+		var endpoint Endpoint
+		if err := item.Decode(&endpoint); err != nil {
+			return -1, err
+		}
+		if strings.TrimSpace(endpoint.Name) == name {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// appendScalar 在映射节点中追加一个字符串键值对
+func appendScalar(mapping *yaml.Node, key string, value string) {
+	mapping.Content = append(mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Value: value},
+	)
+}
+
+// saveConfigNode 以两个空格缩进写回配置文件，已有文件保持原来的权限，新文件权限为 0600
+func saveConfigNode(configPath string, doc *yaml.Node) error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode config file '%s': %v", configPath, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode config file '%s': %v", configPath, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	if err := os.WriteFile(configPath, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write config file '%s': %v", configPath, err)
+	}
+	return nil
+}
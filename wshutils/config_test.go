@@ -0,0 +1,133 @@
+package wshutils
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig 把 content 写入临时配置文件并返回路径
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "wsh.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigsKeepsInvalidEndpoints(t *testing.T) {
+	path := writeConfig(t, `endpoints:
+  - name: good
+    url: ws://localhost:8080/ws
+  - name: bad
+    url: http://localhost:8080/ws
+  - name: good
+    urls: [" ws://localhost:9090/ws ", "ws://localhost:9091/ws"]
+`)
+	config, warnings, err := LoadConfigs([]string{path})
+	if err != nil {
+		t.Fatalf("LoadConfigs failed because of one bad endpoint: %v", err)
+	}
+	if len(config.Endpoints) != 2 {
+		t.Fatalf("got %d endpoints, want good and bad", len(config.Endpoints))
+	}
+	// 同一文件中的重复名称只是警告，后面的定义生效，urls 的第一个地址整理到 url
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "more than once") {
+		t.Errorf("warnings = %q, want one duplicate warning", warnings)
+	}
+	good, err := FindEndpoint(config, "good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if good.URL != "ws://localhost:9090/ws" || !reflect.DeepEqual(good.URLs, []string{"ws://localhost:9091/ws"}) {
+		t.Errorf("good endpoint has url %q and urls %q", good.URL, good.URLs)
+	}
+	if err := good.Validate(); err != nil {
+		t.Errorf("good endpoint: %v", err)
+	}
+
+	bad, err := FindEndpoint(config, "bad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bad.Validate(); err == nil {
+		t.Error("bad endpoint passed Validate")
+	}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "endpoint 'bad'") {
+		t.Errorf("Config.Validate() = %v, want a problem for endpoint 'bad'", err)
+	}
+}
+
+func TestValidateDoesNotModifyConfig(t *testing.T) {
+	config := &Config{Endpoints: []Endpoint{
+		{Name: " spaced ", URL: " ws://localhost/ws ", Tags: []string{" web "}},
+		{Name: "fallback", URLs: []string{"ws://a/ws", "ws://b/ws"}},
+	}}
+	before := make([]Endpoint, len(config.Endpoints))
+	for i, endpoint := range config.Endpoints {
+		before[i] = endpoint
+		before[i].Tags = append([]string(nil), endpoint.Tags...)
+		before[i].URLs = append([]string(nil), endpoint.URLs...)
+	}
+
+	config.Validate()
+	for i := range config.Endpoints {
+		config.Endpoints[i].Validate()
+	}
+	for i, endpoint := range config.Endpoints {
+		if endpoint.Name != before[i].Name || endpoint.URL != before[i].URL ||
+			!reflect.DeepEqual(endpoint.Tags, before[i].Tags) || !reflect.DeepEqual(endpoint.URLs, before[i].URLs) {
+			t.Errorf("endpoint #%d changed from %+v to %+v", i+1, before[i], endpoint)
+		}
+	}
+}
+
+func TestProfileFlagValuesFormatsNumbers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wsh.json")
+	content := `{"profiles": {"big": {"endpoint": "prod", "max-bytes": 1000000, "heartbeat-interval": 30, "read-timeout": 1.5, "env": ["A=1", 2]}}}`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := FindProfile(config, "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// JSON 中的数字解码为 float64，不能写成 1e+06
+	tests := map[string][]string{
+		"max-bytes":          {"1000000"},
+		"heartbeat-interval": {"30"},
+		"read-timeout":       {"1.5"},
+		"env":                {"A=1", "2"},
+	}
+	for name, want := range tests {
+		if got := profile.FlagValues(name); !reflect.DeepEqual(got, want) {
+			t.Errorf("FlagValues(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestConfirmEndpointLeavesRemainingInput(t *testing.T) {
+	endpoint := &Endpoint{Name: "prod", Confirm: true}
+	in := strings.NewReader("prod\nls\n")
+	var out strings.Builder
+	if err := ConfirmEndpoint(endpoint, in, &out); err != nil {
+		t.Fatalf("ConfirmEndpoint: %v", err)
+	}
+	// 确认行之后的内容仍然留给会话
+	rest, _ := io.ReadAll(in)
+	if string(rest) != "ls\n" {
+		t.Errorf("input after the confirmation = %q, want %q", rest, "ls\n")
+	}
+
+	if err := ConfirmEndpoint(endpoint, strings.NewReader("staging\n"), &out); err == nil {
+		t.Error("wrong endpoint name accepted")
+	}
+}
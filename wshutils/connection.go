@@ -1,32 +1,30 @@
 package wshutils
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v3"
 
 	"github.com/gorilla/websocket"
-	"golang.org/x/term"
 )
 
-type Endpoint struct {
-	Name        string `yaml:"name"`
-	URL         string `yaml:"url"`
-	Description string `yaml:"description"`
-}
-
-type Config struct {
-	Endpoints []Endpoint `yaml:"endpoints"`
-}
-
 type CmdMsg struct {
 	Type string `json:"type"`
 	Cmd  string `json:"cmd,omitempty"`
@@ -43,73 +41,600 @@ type HeartbeatMsg struct {
 	Data string `json:"data"`
 }
 
+// ControlMsg 服务端发送的控制消息，只解析 type 字段
+type ControlMsg struct {
+	Type string `json:"type"`
+}
+
+// 服务端流控消息类型
+const (
+	// PauseMsgType 要求客户端暂停发送输入
+	PauseMsgType = "pause"
+	// ResumeMsgType 允许客户端恢复发送输入
+	ResumeMsgType = "resume"
+)
+
+// MessageHandler 控制消息处理函数，参数为完整的消息内容
+type MessageHandler func(data []byte)
+
+// ConnectionInterface 收发消息所需的最小接口，*Connection 实现了该接口，测试时可替换为内存实现
+type ConnectionInterface interface {
+	SendJSON(v interface{}) error
+	SendText(data string) error
+	SendBinary(data []byte) error
+	ReadMessage() (messageType int, p []byte, err error)
+	Close() error
+}
+
+// 确保 *Connection 实现 ConnectionInterface
+var _ ConnectionInterface = (*Connection)(nil)
+
 // Connection 封装WebSocket连接和相关功能
+// 发送方法（SendJSON、SendText、SendBinary、ResizeTerm 等）可以在多个协程中并发调用，写操作由 writeMutex 串行化；
+// 读取只能在一个协程中进行
 type Connection struct {
-	conn *websocket.Conn
+	// conn 底层连接，重连时会被替换，通过 ws() 访问
+	conn      *websocket.Conn
+	connMutex sync.RWMutex
+	// writeMutex 串行化数据帧的写入，gorilla/websocket 不允许并发调用 WriteMessage
+	writeMutex sync.Mutex
+	// extensions 服务端接受的扩展，与 conn 一起更新
+	extensions string
+	// pending 中继确认行之后已经收到的会话数据，下一次 ReadMessage 先返回它，与 conn 一起更新
+	pending []byte
+	// closed 连接已被主动关闭，不再重连
+	closed atomic.Bool
+	// done 主动关闭时关闭，心跳等后台协程据此退出
+	done     chan struct{}
+	doneOnce sync.Once
+	// readers 正在 ReadMessage 中阻塞的协程数
+	readers atomic.Int32
+	// sawMessage 当前底层连接上收到过消息，重连后重置
+	sawMessage atomic.Bool
+	// peerClosed 连接关闭后读取返回错误（通常是收到服务端的关闭帧）时关闭
+	peerClosed     chan struct{}
+	peerClosedOnce sync.Once
+
+	// 重连使用的地址和选项，targetURL 是当前连接使用的地址，urls 是全部候选地址
+	targetURL string
+	urls      []string
+	opts      ConnectionOptions
+
+	// lastPong 最近一次收到 pong 的时间（UnixNano）
+	lastPong atomic.Int64
+	// lastPing 最近一次发送 ping 的时间（UnixNano），latency 最近一次 ping 的往返时间（纳秒）
+	lastPing atomic.Int64
+	latency  atomic.Int64
+
+	// ping 心跳和延迟测量共用一个发送协程，pingInterval 是请求过的最短间隔（纳秒）
+	// 间隔变短时通过 pingReset 通知发送协程
+	pingOnce     sync.Once
+	pingInterval atomic.Int64
+	pingReset    chan struct{}
+
+	// 流量统计，只计算消息负载
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+
+	// 控制消息处理函数，按消息类型注册
+	handlersMutex sync.RWMutex
+	handlers      map[string]MessageHandler
 }
 
-// GetDefaultConfigPath 获取默认配置文件路径
-func GetDefaultConfigPath() string {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "config.yaml" // fallback to local config.yaml
+// ConnectionStats 连接的流量统计
+type ConnectionStats struct {
+	BytesSent     int64
+	BytesReceived int64
+}
+
+// Total 发送和接收的字节总数
+func (s ConnectionStats) Total() int64 {
+	return s.BytesSent + s.BytesReceived
+}
+
+// DisconnectReason 连接断开的原因
+type DisconnectReason int
+
+const (
+	// DisconnectDropped 连接异常中断（网络错误、没有关闭帧就断开），可以尝试重连
+	DisconnectDropped DisconnectReason = iota
+	// DisconnectRemoteExit 远端shell正常退出，不应重连
+	DisconnectRemoteExit
+	// DisconnectAbnormalClose 服务端发送了不在正常退出关闭码中的关闭帧，如 1011
+	DisconnectAbnormalClose
+	// DisconnectTimeout 超过 ReadTimeout 没有收到数据
+	DisconnectTimeout
+	// DisconnectMessageTooLarge 服务端发送的消息超过了 MaxMessageSize，重连后很可能再次发生
+	DisconnectMessageTooLarge
+)
+
+// String 断开原因的描述，用于日志和错误提示
+func (r DisconnectReason) String() string {
+	switch r {
+	case DisconnectRemoteExit:
+		return "remote shell exited"
+	case DisconnectAbnormalClose:
+		return "closed by server"
+	case DisconnectTimeout:
+		return "read timed out"
+	case DisconnectMessageTooLarge:
+		return "message too large"
+	default:
+		return "connection dropped"
 	}
-	return filepath.Join(homeDir, ".config", "wsh.yaml")
 }
 
-// LoadConfig 加载配置文件
-func LoadConfig(configPath string) (*Config, error) {
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file '%s': %v", configPath, err)
+// Reconnectable 该原因断开后是否值得重连
+func (r DisconnectReason) Reconnectable() bool {
+	switch r {
+	case DisconnectDropped, DisconnectAbnormalClose, DisconnectTimeout:
+		return true
+	default:
+		return false
 	}
+}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file '%s': %v", configPath, err)
+// DefaultRemoteExitCodes 默认视为远端正常退出的关闭码
+var DefaultRemoteExitCodes = []int{websocket.CloseNormalClosure, websocket.CloseGoingAway}
+
+// ClassifyDisconnect 根据读错误判断断开原因，只有收到过shell输出后的正常关闭才视为远端退出
+// 还没有输出就正常关闭通常是服务端拒绝了会话，按连接中断处理
+func ClassifyDisconnect(err error, sawOutput bool, exitCodes []int) DisconnectReason {
+	var closeErr *websocket.CloseError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &closeErr):
+		if slices.Contains(exitCodes, closeErr.Code) {
+			if sawOutput {
+				return DisconnectRemoteExit
+			}
+			return DisconnectDropped
+		}
+		// 1006 是 gorilla/websocket 在没有收到关闭帧就断开时生成的，并不是服务端发送的
+		if closeErr.Code == websocket.CloseAbnormalClosure {
+			return DisconnectDropped
+		}
+		return DisconnectAbnormalClose
+	case errors.Is(err, websocket.ErrReadLimit):
+		return DisconnectMessageTooLarge
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return DisconnectTimeout
 	}
+	return DisconnectDropped
+}
 
-	return &config, nil
+// ReadError Read 失败时返回的错误，Reason 是断开的原因
+type ReadError struct {
+	Reason DisconnectReason
+	Err    error
 }
 
-// FindEndpoint 根据名称查找端点
-func FindEndpoint(config *Config, name string) (*Endpoint, error) {
-	for _, endpoint := range config.Endpoints {
-		if endpoint.Name == name {
-			return &endpoint, nil
-		}
+func (e *ReadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// DisconnectReasonOf 返回 Read 错误中的断开原因，其他错误视为 DisconnectDropped
+func DisconnectReasonOf(err error) DisconnectReason {
+	var readErr *ReadError
+	if errors.As(err, &readErr) {
+		return readErr.Reason
 	}
-	return nil, fmt.Errorf("endpoint '%s' not found in config", name)
+	return DisconnectDropped
 }
 
-// IsURL 检查字符串是否为URL
-func IsURL(s string) bool {
-	return len(s) > 6 && (s[:6] == "ws://" || s[:7] == "wss://")
+// ConnectionOptions 建立连接时的可选参数
+type ConnectionOptions struct {
+	// BindAddr 本地源IP，为空时由系统选择出口地址
+	BindAddr string
+	// CAFile 额外信任的CA证书（PEM），用于私有CA签发的 wss:// 服务
+	CAFile string
+	// Insecure 跳过 wss:// 服务端证书校验，仅用于测试环境
+	Insecure bool
+	// Headers 握手请求附带的HTTP头，值中的 ${VAR} 会展开为环境变量
+	Headers map[string]string
+	// Query 握手 URL 附带的查询参数，覆盖 URL 中的同名参数，值中的 ${VAR} 会展开为环境变量
+	// 只在拨号时加入 URL，不出现在连接提示和日志中
+	Query map[string]string
+	// ConnectTimeout 建立连接（TCP + TLS + 握手）的超时时间，0 使用默认值
+	ConnectTimeout time.Duration
+	// TCPKeepAlive TCP keepalive 探测间隔，用于发现 NAT 后面半开的连接；0 使用 DefaultTCPKeepAlive，负数表示关闭
+	// 经过代理时作用于到代理的连接
+	TCPKeepAlive time.Duration
+	// Proxy 代理地址（http:// 或 socks5://），为空时使用 HTTP_PROXY/HTTPS_PROXY/ALL_PROXY 环境变量
+	Proxy string
+	// Compress 协商 permessage-deflate 压缩，服务端不支持时使用未压缩的连接
+	Compress bool
+	// ReadTimeout 每次 ReadMessage 最长等待时间，收到 pong 时也会延长，0 表示不限制
+	ReadTimeout time.Duration
+	// WriteTimeout 每次发送消息的最长阻塞时间，0 表示不限制
+	WriteTimeout time.Duration
+	// MaxMessageSize 接收单条消息的最大字节数，0 使用默认值
+	MaxMessageSize int64
+	// TermSize stdout 不是终端且没有设置 COLUMNS/LINES 时 ResizeTerm 发送的大小，零值使用 DefaultTermSize
+	TermSize TermSize
+	// SkipUnknownSize 无法确定终端大小时 ResizeTerm 不发送 resize 消息，由服务端使用自己的默认大小
+	SkipUnknownSize bool
+	// RemoteExitCodes Read 视为远端正常退出的关闭码，nil 时使用 DefaultRemoteExitCodes
+	RemoteExitCodes []int
+	// Quiet 不在 stderr 上输出 "Connecting to ..." 提示，警告仍会输出
+	Quiet bool
+	// Relay 不为 nil 时 URL 指向跳板中继，建连和重连后先请求中继连接 Relay.Target
+	Relay *Relay
+	// Subprotocols 通过 Sec-WebSocket-Protocol 请求的子协议，服务端没有接受其中之一时建连失败
+	Subprotocols []string
+	// FallbackURLs 目标地址连接失败时依次尝试的其他地址，重连时从当前地址开始轮流尝试
+	FallbackURLs []string
+	// InitMessage 建连和重连后（中继握手之后）立即发送的文本消息，如认证用的 JSON，${VAR} 会展开为环境变量
+	InitMessage string
 }
 
+// readLimit 接收单条消息的最大字节数
+func (opts ConnectionOptions) readLimit() int64 {
+	if opts.MaxMessageSize <= 0 {
+		return DefaultMaxMessageSize
+	}
+	return opts.MaxMessageSize
+}
+
+// DefaultConnectTimeout 默认建连超时时间
+const DefaultConnectTimeout = 10 * time.Second
+
+// DefaultMaxMessageSize 默认接收单条消息的最大字节数，防止异常的超大帧耗尽内存
+const DefaultMaxMessageSize = 1 << 20
+
+// DefaultTCPKeepAlive 默认的 TCP keepalive 探测间隔，与 net.Dialer 的默认值相同
+const DefaultTCPKeepAlive = 15 * time.Second
+
+// DefaultCloseTimeout 正常关闭时等待服务端回应关闭帧的时间
+const DefaultCloseTimeout = time.Second
+
 // NewConnection 创建新的连接
 func NewConnection(targetURL string) (*Connection, error) {
-	u, err := url.Parse(targetURL)
-	if err != nil {
-		return nil, fmt.Errorf("invalid URL: %v", err)
+	return NewConnectionWithOptions(targetURL, ConnectionOptions{})
+}
+
+// NewConnectionWithOptions 按指定选项创建新的连接，targetURL 连接失败时依次尝试 opts.FallbackURLs
+func NewConnectionWithOptions(targetURL string, opts ConnectionOptions) (*Connection, error) {
+	var urls []string
+	hasWSS := false
+	for _, candidate := range append([]string{targetURL}, opts.FallbackURLs...) {
+		u, err := url.Parse(candidate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL: %v", err)
+		}
+		urls = append(urls, u.String())
+		hasWSS = hasWSS || u.Scheme == "wss"
 	}
 
 	logrus.SetLevel(logrus.ErrorLevel)
 
-	fmt.Printf("Connecting to %s...\n", u.String())
+	if opts.Insecure && hasWSS {
+		fmt.Fprintln(os.Stderr, "Warning: TLS certificate verification is disabled (--insecure), do not use this in production")
+	}
+
+	var err error
+	for i, candidate := range urls {
+		if !opts.Quiet {
+			fmt.Fprintf(os.Stderr, "Connecting to %s...\n", candidate)
+		}
+
+		// 连接 WebSocket
+		var c *websocket.Conn
+		var extensions string
+		var pending []byte
+		c, extensions, pending, err = connect(candidate, opts)
+		if err == nil {
+			conn := &Connection{conn: c, extensions: extensions, pending: pending, targetURL: candidate, urls: urls, opts: opts, peerClosed: make(chan struct{}), done: make(chan struct{}), pingReset: make(chan struct{}, 1)}
+			conn.installPongHandler(c)
+			return conn, nil
+		}
+		if i < len(urls)-1 {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to connect to %s: %v, trying next URL\n", candidate, err)
+		}
+	}
+	if len(urls) > 1 {
+		return nil, fmt.Errorf("all %d URLs failed, last error: %v", len(urls), err)
+	}
+	return nil, err
+}
+
+// connect 拨号并完成会话建立（中继握手、InitMessage）
+func connect(targetURL string, opts ConnectionOptions) (*websocket.Conn, string, []byte, error) {
+	c, extensions, err := dial(targetURL, opts)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	pending, err := openSession(c, opts)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return c, extensions, pending, nil
+}
+
+// openSession 在新建立的连接上完成中继握手并发送 InitMessage，返回中继确认后已收到的会话数据，失败时关闭连接
+func openSession(c *websocket.Conn, opts ConnectionOptions) ([]byte, error) {
+	pending, err := openRelay(c, opts.Relay)
+	if err != nil {
+		return nil, err
+	}
+	if opts.InitMessage != "" {
+		if opts.WriteTimeout > 0 {
+			c.SetWriteDeadline(time.Now().Add(opts.WriteTimeout))
+		}
+		if err := c.WriteMessage(websocket.TextMessage, []byte(os.ExpandEnv(opts.InitMessage))); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to send init message: %v", err)
+		}
+	}
+	return pending, nil
+}
+
+// dial 按选项拨号建立WebSocket连接，同时返回服务端接受的扩展（Sec-WebSocket-Extensions）
+func dial(targetURL string, opts ConnectionOptions) (*websocket.Conn, string, error) {
+	dialer, err := newDialer(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// ws+unix 地址改为通过 Unix 域套接字拨号，握手仍使用原来的 HTTP 路径
+	if strings.HasPrefix(targetURL, unixURLPrefix) {
+		if opts.BindAddr != "" {
+			return nil, "", fmt.Errorf("--bind cannot be used with %s URLs", unixURLPrefix)
+		}
+		socketPath, dialURL, err := splitUnixURL(targetURL)
+		if err != nil {
+			return nil, "", err
+		}
+		netDialer := &net.Dialer{Timeout: dialer.HandshakeTimeout}
+		dialer.NetDialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return netDialer.DialContext(ctx, "unix", socketPath)
+		}
+		dialer.Proxy = nil
+		targetURL = dialURL
+	}
+
+	targetURL, err = applyQuery(targetURL, opts.Query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c, resp, err := dialer.Dial(targetURL, buildHeader(opts.Headers))
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() || errors.Is(err, context.DeadlineExceeded) {
+			return nil, "", fmt.Errorf("connection timed out after %v", dialer.HandshakeTimeout)
+		}
+		return nil, "", fmt.Errorf("dial error: %v", err)
+	}
+
+	if len(opts.Subprotocols) > 0 && !slices.Contains(opts.Subprotocols, c.Subprotocol()) {
+		c.Close()
+		if c.Subprotocol() == "" {
+			return nil, "", fmt.Errorf("server did not accept subprotocol %s", strings.Join(opts.Subprotocols, ", "))
+		}
+		return nil, "", fmt.Errorf("server selected subprotocol '%s', which was not requested", c.Subprotocol())
+	}
+
+	c.SetReadLimit(opts.readLimit())
+
+	extensions := resp.Header.Get("Sec-WebSocket-Extensions")
+	// 服务端没有接受 permessage-deflate 时 gorilla 会忽略写压缩设置
+	if opts.Compress {
+		c.EnableWriteCompression(true)
+	}
+	return c, extensions, nil
+}
+
+// newDialer 根据选项构造WebSocket拨号器
+func newDialer(opts ConnectionOptions) (*websocket.Dialer, error) {
+	dialer := *websocket.DefaultDialer
+	netDialer := &net.Dialer{}
+
+	timeout := opts.ConnectTimeout
+	if timeout <= 0 {
+		timeout = DefaultConnectTimeout
+	}
+	dialer.HandshakeTimeout = timeout
+	netDialer.Timeout = timeout
+	dialer.Subprotocols = opts.Subprotocols
+	// net.Dialer 在握手之前对 TCP 连接调用 SetKeepAlive 和 SetKeepAlivePeriod
+	netDialer.KeepAlive = opts.TCPKeepAlive
+	if netDialer.KeepAlive == 0 {
+		netDialer.KeepAlive = DefaultTCPKeepAlive
+	}
+	dialer.EnableCompression = opts.Compress
+
+	if opts.BindAddr != "" {
+		ip := net.ParseIP(opts.BindAddr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid bind address '%s': not an IP address", opts.BindAddr)
+		}
+		netDialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	// 经过代理时 wss:// 在 CONNECT 隧道建立后再进行 TLS 握手
+	dialer.Proxy = proxyFromEnvironment
+	if opts.Proxy != "" {
+		proxyURL, err := parseProxyURL(opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		dialer.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if opts.CAFile != "" {
+		tlsConfig, err := loadCAConfig(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		dialer.TLSClientConfig = tlsConfig
+	}
+	if opts.Insecure {
+		if dialer.TLSClientConfig == nil {
+			dialer.TLSClientConfig = &tls.Config{}
+		}
+		dialer.TLSClientConfig.InsecureSkipVerify = true
+	}
+
+	dialer.NetDialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := netDialer.DialContext(ctx, network, addr)
+		if err != nil && opts.BindAddr != "" {
+			return nil, fmt.Errorf("failed to dial %s from local address %s: %w", addr, opts.BindAddr, err)
+		}
+		return c, err
+	}
+
+	return &dialer, nil
+}
+
+// buildHeader 构造握手请求的HTTP头，展开值中的环境变量
+func buildHeader(headers map[string]string) http.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	header := http.Header{}
+	for name, value := range headers {
+		header.Set(name, os.ExpandEnv(value))
+	}
+	return header
+}
+
+// applyQuery 在握手 URL 上设置查询参数，保留 URL 中的其他参数
+func applyQuery(targetURL string, query map[string]string) (string, error) {
+	if len(query) == 0 {
+		return targetURL, nil
+	}
+
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %v", err)
+	}
+	values := u.Query()
+	for name, value := range query {
+		values.Set(name, os.ExpandEnv(value))
+	}
+	u.RawQuery = values.Encode()
+	return u.String(), nil
+}
 
-	// 连接 WebSocket
-	c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+// loadCAConfig 读取PEM格式的CA证书，构造只信任这些证书的TLS配置
+func loadCAConfig(caFile string) (*tls.Config, error) {
+	data, err := os.ReadFile(caFile)
 	if err != nil {
-		return nil, fmt.Errorf("dial error: %v", err)
+		return nil, fmt.Errorf("failed to read CA file '%s': %v", caFile, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("failed to parse CA file '%s': no valid PEM certificates found", caFile)
 	}
 
-	return &Connection{conn: c}, nil
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// Extensions 返回握手时服务端接受的扩展，如 "permessage-deflate; client_no_context_takeover"
+func (conn *Connection) Extensions() string {
+	conn.connMutex.RLock()
+	defer conn.connMutex.RUnlock()
+	return conn.extensions
+}
+
+// URL 当前连接使用的地址，配置了多个地址时可能不是第一个
+func (conn *Connection) URL() string {
+	conn.connMutex.RLock()
+	defer conn.connMutex.RUnlock()
+	return conn.targetURL
+}
+
+// Subprotocol 返回握手时服务端接受的子协议，没有请求子协议时为空
+func (conn *Connection) Subprotocol() string {
+	return conn.ws().Subprotocol()
+}
+
+// ws 获取当前的底层连接
+func (conn *Connection) ws() *websocket.Conn {
+	conn.connMutex.RLock()
+	defer conn.connMutex.RUnlock()
+	return conn.conn
 }
 
-// Close 关闭连接
+// SetReadDeadline 设置底层连接的读超时时间点，零值表示不超时
+func (conn *Connection) SetReadDeadline(t time.Time) error {
+	return conn.ws().SetReadDeadline(t)
+}
+
+// SetReadLimit 设置接收单条消息的最大字节数，超出时 ReadMessage 返回错误并关闭连接，重连后仍然生效
+func (conn *Connection) SetReadLimit(n int64) {
+	conn.connMutex.Lock()
+	defer conn.connMutex.Unlock()
+	conn.opts.MaxMessageSize = n
+	conn.conn.SetReadLimit(n)
+}
+
+// SetWriteDeadline 设置底层连接的写超时时间点，零值表示不超时
+func (conn *Connection) SetWriteDeadline(t time.Time) error {
+	return conn.ws().SetWriteDeadline(t)
+}
+
+// Close 关闭连接，关闭后不会再重连
 func (conn *Connection) Close() error {
-	return conn.conn.Close()
+	conn.closed.Store(true)
+	conn.closeDone()
+	return conn.ws().Close()
+}
+
+// CloseGracefully 发送 CloseNormalClosure 关闭帧，等待服务端回应后再关闭底层连接，关闭后不会再重连
+// 服务端会把断开记录为正常关闭；出错退出的路径仍使用 Close 立即断开
+func (conn *Connection) CloseGracefully() error {
+	if conn.closed.Swap(true) {
+		return nil
+	}
+	conn.closeDone()
+	c := conn.ws()
+	deadline := time.Now().Add(DefaultCloseTimeout)
+
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	if err := c.WriteControl(websocket.CloseMessage, msg, deadline); err != nil {
+		logrus.WithError(err).Debug("Failed to send close frame")
+		return c.Close()
+	}
+
+	if conn.readers.Load() > 0 {
+		// 已有协程在读取，由它收到服务端的关闭帧后通知
+		select {
+		case <-conn.peerClosed:
+		case <-time.After(DefaultCloseTimeout):
+		}
+	} else {
+		// 丢弃剩余消息，直到收到关闭帧或超时
+		c.SetReadDeadline(deadline)
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				break
+			}
+		}
+	}
+	return c.Close()
+}
+
+// Done 返回连接被主动关闭（Close 或 CloseGracefully）时关闭的通道，重连期间不会关闭
+func (conn *Connection) Done() <-chan struct{} {
+	return conn.done
+}
+
+// closeDone 关闭 done 通道，可以重复调用
+func (conn *Connection) closeDone() {
+	conn.doneOnce.Do(func() { close(conn.done) })
+}
+
+// IsClosed 连接是否已被主动关闭
+func (conn *Connection) IsClosed() bool {
+	return conn.closed.Load()
 }
 
 // SendJSON 发送JSON消息
@@ -118,41 +643,180 @@ func (conn *Connection) SendJSON(v interface{}) error {
 	if err != nil {
 		return err
 	}
-	return conn.conn.WriteMessage(websocket.TextMessage, data)
+	return conn.writeMessage(websocket.TextMessage, data)
 }
 
 // SendText 发送文本消息
 func (conn *Connection) SendText(data string) error {
-	return conn.conn.WriteMessage(websocket.TextMessage, []byte(data))
+	return conn.writeMessage(websocket.TextMessage, []byte(data))
+}
+
+// SendBinary 以二进制帧发送原始字节，需要服务端把二进制帧原样写入 shell 的标准输入
+func (conn *Connection) SendBinary(data []byte) error {
+	return conn.writeMessage(websocket.BinaryMessage, data)
+}
+
+// writeMessage 写入消息并记录发送字节数，可并发调用
+// 控制帧通过 WriteControl 发送，它本身允许与其他写操作并发，不需要加锁
+func (conn *Connection) writeMessage(messageType int, data []byte) error {
+	conn.writeMutex.Lock()
+	defer conn.writeMutex.Unlock()
+
+	c := conn.ws()
+	if conn.opts.WriteTimeout > 0 {
+		c.SetWriteDeadline(time.Now().Add(conn.opts.WriteTimeout))
+	}
+	if err := c.WriteMessage(messageType, data); err != nil {
+		return err
+	}
+	conn.bytesSent.Add(int64(len(data)))
+	return nil
 }
 
-// ReadMessage 读取消息
+// ReadMessage 读取消息，设置了 ReadTimeout 时超时返回错误，之后连接不可再读，需要重连
 func (conn *Connection) ReadMessage() (messageType int, p []byte, err error) {
-	return conn.conn.ReadMessage()
+	conn.connMutex.Lock()
+	pending := conn.pending
+	conn.pending = nil
+	conn.connMutex.Unlock()
+	if len(pending) > 0 {
+		conn.bytesReceived.Add(int64(len(pending)))
+		conn.sawMessage.Store(true)
+		return websocket.TextMessage, pending, nil
+	}
+
+	c := conn.ws()
+	if conn.opts.ReadTimeout > 0 {
+		c.SetReadDeadline(time.Now().Add(conn.opts.ReadTimeout))
+	}
+	conn.readers.Add(1)
+	messageType, p, err = c.ReadMessage()
+	conn.readers.Add(-1)
+	conn.bytesReceived.Add(int64(len(p)))
+	if err == nil {
+		conn.sawMessage.Store(true)
+	}
+
+	if err != nil && conn.IsClosed() {
+		conn.peerClosedOnce.Do(func() { close(conn.peerClosed) })
+	}
+
+	var netErr net.Error
+	if conn.opts.ReadTimeout > 0 && errors.As(err, &netErr) && netErr.Timeout() {
+		err = fmt.Errorf("no data received from server for %v: %w", conn.opts.ReadTimeout, err)
+	}
+	if errors.Is(err, websocket.ErrReadLimit) {
+		conn.connMutex.RLock()
+		limit := conn.opts.readLimit()
+		conn.connMutex.RUnlock()
+		err = fmt.Errorf("server sent a message larger than the %d byte limit: %w", limit, err)
+	}
+	return messageType, p, err
+}
+
+// Read 与 ReadMessage 相同，出错时返回 *ReadError，按 RemoteExitCodes 和当前连接是否收到过消息判断断开原因
+func (conn *Connection) Read() (messageType int, p []byte, err error) {
+	messageType, p, err = conn.ReadMessage()
+	if err != nil {
+		exitCodes := conn.opts.RemoteExitCodes
+		if exitCodes == nil {
+			exitCodes = DefaultRemoteExitCodes
+		}
+		return messageType, p, &ReadError{Reason: ClassifyDisconnect(err, conn.sawMessage.Load(), exitCodes), Err: err}
+	}
+	return messageType, p, nil
 }
 
-// ResizeTerm 调整终端大小
+// HandleMessage 注册指定类型控制消息的处理函数
+func (conn *Connection) HandleMessage(msgType string, handler MessageHandler) {
+	conn.handlersMutex.Lock()
+	defer conn.handlersMutex.Unlock()
+
+	if conn.handlers == nil {
+		conn.handlers = make(map[string]MessageHandler)
+	}
+	conn.handlers[msgType] = handler
+}
+
+// DispatchMessage 如果消息是已注册类型的控制消息则交给处理函数，返回是否已处理
+func (conn *Connection) DispatchMessage(data []byte) bool {
+	conn.handlersMutex.RLock()
+	defer conn.handlersMutex.RUnlock()
+
+	// 普通shell输出不会是JSON对象，先做廉价检查
+	if len(conn.handlers) == 0 || len(data) == 0 || data[0] != '{' {
+		return false
+	}
+
+	var msg ControlMsg
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return false
+	}
+	handler, ok := conn.handlers[msg.Type]
+	if !ok {
+		return false
+	}
+
+	handler(data)
+	return true
+}
+
+// Stats 获取流量统计
+func (conn *Connection) Stats() ConnectionStats {
+	return ConnectionStats{
+		BytesSent:     conn.bytesSent.Load(),
+		BytesReceived: conn.bytesReceived.Load(),
+	}
+}
+
+// ResizeTerm 调整终端大小，大小依次取自 stdout 终端、COLUMNS/LINES 环境变量、
+// ConnectionOptions.TermSize 和 DefaultTermSize；设置了 SkipUnknownSize 时不使用后两者，直接跳过
 func (conn *Connection) ResizeTerm() error {
-	cols, rows, errGetSize := term.GetSize(int(os.Stdout.Fd()))
-	if errGetSize != nil {
-		rows = 47
-		cols = 196
+	size, ok := TerminalSize()
+	if !ok {
+		switch {
+		case conn.opts.SkipUnknownSize:
+			logrus.Debug("Terminal size unknown, not sending resize")
+			return nil
+		case !conn.opts.TermSize.IsZero():
+			size = conn.opts.TermSize
+		default:
+			size = DefaultTermSize
+		}
 	}
 
-	return conn.SendJSON(ResizeMsg{Type: "resize", Rows: rows, Cols: cols})
+	return conn.SendJSON(ResizeMsg{Type: "resize", Rows: size.Rows, Cols: size.Cols})
+}
+
+// ForwardedSignals 捕获后转发给远端的信号及对应的控制字符，wsh 本身不会因此中断或挂起：
+// SIGINT 对应 Ctrl+C，SIGQUIT 对应 Ctrl+\，SIGTSTP 对应 Ctrl+Z
+var ForwardedSignals = map[os.Signal]byte{
+	syscall.SIGINT:  0x03,
+	syscall.SIGQUIT: 0x1c,
+	syscall.SIGTSTP: 0x1a,
+}
+
+// ForwardSignal 把信号对应的控制字符发送给远端，不在 ForwardedSignals 中的信号返回 false
+func (conn *Connection) ForwardSignal(sig os.Signal) bool {
+	b, ok := ForwardedSignals[sig]
+	if !ok {
+		return false
+	}
+	conn.SendJSON(CmdMsg{Type: "cmd", Cmd: string([]byte{b})})
+	return true
 }
 
 // SetupSignalHandlers 设置信号处理器
 func (conn *Connection) SetupSignalHandlers() {
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGWINCH)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTSTP, syscall.SIGWINCH)
 	go func() {
 		for sig := range sigs {
 			switch sig {
-			case syscall.SIGINT:
-				conn.SendJSON(CmdMsg{Type: "cmd", Cmd: string([]byte{3})}) // Ctrl+C
 			case syscall.SIGWINCH:
 				conn.ResizeTerm()
+			default:
+				conn.ForwardSignal(sig)
 			}
 		}
 	}()
@@ -160,15 +824,145 @@ func (conn *Connection) SetupSignalHandlers() {
 
 // StartHeartbeat 开始心跳
 func (conn *Connection) StartHeartbeat() {
+	StartHeartbeat(conn, 30*time.Second)
+}
+
+// closeNotifier 可以通知主动关闭的连接，如 *Connection
+type closeNotifier interface {
+	Done() <-chan struct{}
+}
+
+// StartHeartbeat 每隔 interval 发送一次 JSON 心跳，连接关闭或发送失败后停止
+// 连接实现了 Done 时关闭后立即退出，否则在下一次发送失败时退出
+func StartHeartbeat(c ConnectionInterface, interval time.Duration) {
+	var done <-chan struct{}
+	if notifier, ok := c.(closeNotifier); ok {
+		done = notifier.Done()
+	}
 	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
 		for {
-			time.Sleep(30 * time.Second)
-			conn.SendJSON(HeartbeatMsg{Type: "heartbeat", Data: ""})
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if err := c.SendJSON(HeartbeatMsg{Type: "heartbeat", Data: ""}); err != nil {
+				logrus.WithError(err).Debug("Failed to send heartbeat")
+				return
+			}
 		}
 	}()
 }
 
-// GetConn 获取原始连接
+// StartPingHeartbeat 使用 WebSocket ping 控制帧作为心跳，连接关闭后停止
+// 与 StartLatencyProbe 共用同一个 ping 协程，同时启用时按较短的间隔发送
+func (conn *Connection) StartPingHeartbeat(interval time.Duration) {
+	conn.startPinging(interval)
+}
+
+// StartLatencyProbe 每隔 interval 发送一次 ping 测量往返时间，结果通过 Latency 读取，连接关闭后停止
+// 已经启用 ping 心跳时不再另起协程，两者读取同一组 ping 的结果
+func (conn *Connection) StartLatencyProbe(interval time.Duration) {
+	conn.startPinging(interval)
+}
+
+// startPinging 定时发送 ping，连接关闭后停止
+// 多次调用只启动一个协程，发送间隔取各次调用中最短的一个，非正数的间隔被忽略
+func (conn *Connection) startPinging(interval time.Duration) {
+	if interval <= 0 {
+		logrus.Debugf("Ignoring non-positive ping interval %v", interval)
+		return
+	}
+	for {
+		current := conn.pingInterval.Load()
+		if current != 0 && current <= int64(interval) {
+			break
+		}
+		if conn.pingInterval.CompareAndSwap(current, int64(interval)) {
+			select {
+			case conn.pingReset <- struct{}{}:
+			default:
+			}
+			break
+		}
+	}
+	conn.pingOnce.Do(func() { go conn.pingLoop() })
+}
+
+// pingLoop 按 pingInterval 发送 ping，间隔变化时重置定时器
+func (conn *Connection) pingLoop() {
+	interval := time.Duration(conn.pingInterval.Load())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-conn.done:
+			return
+		case <-conn.pingReset:
+			interval = time.Duration(conn.pingInterval.Load())
+			ticker.Reset(interval)
+			continue
+		case <-ticker.C:
+		}
+		if err := conn.Ping(time.Now().Add(interval)); err != nil {
+			logrus.WithError(err).Debug("Failed to send ping")
+		}
+	}
+}
+
+// Ping 发送携带发送时间的 ping 控制帧，收到对应的 pong 时更新 Latency
+func (conn *Connection) Ping(deadline time.Time) error {
+	now := time.Now().UnixNano()
+	conn.lastPing.Store(now)
+	// WriteControl 可以与其他写操作并发调用
+	return conn.ws().WriteControl(websocket.PingMessage, []byte(strconv.FormatInt(now, 10)), deadline)
+}
+
+// LastPong 最近一次收到 pong 的时间，从未收到时返回零值
+func (conn *Connection) LastPong() time.Time {
+	nanos := conn.lastPong.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// LastPing 最近一次通过 Ping 发送 ping 的时间，从未发送时返回零值
+func (conn *Connection) LastPing() time.Time {
+	nanos := conn.lastPing.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// Latency 最近一次 ping 的往返时间，尚未测量到时返回0
+func (conn *Connection) Latency() time.Duration {
+	return time.Duration(conn.latency.Load())
+}
+
+// installPongHandler 在底层连接上记录 pong 时间和往返时间，只有在读取消息时才会触发
+func (conn *Connection) installPongHandler(c *websocket.Conn) {
+	c.SetPongHandler(func(appData string) error {
+		now := time.Now().UnixNano()
+		conn.lastPong.Store(now)
+		// 服务端主动发送的 pong 不带发送时间，不参与测量
+		if sent, err := strconv.ParseInt(appData, 10, 64); err == nil && sent > 0 && sent <= now {
+			conn.latency.Store(now - sent)
+		}
+		// pong 说明连接仍然存活，即使 shell 没有输出也不算超时
+		if conn.opts.ReadTimeout > 0 {
+			c.SetReadDeadline(time.Now().Add(conn.opts.ReadTimeout))
+		}
+		return nil
+	})
+}
+
+// GetConn 获取原始连接，直接在原始连接上写数据帧不受 Connection 的写锁保护
 func (conn *Connection) GetConn() *websocket.Conn {
-	return conn.conn
+	return conn.ws()
 }
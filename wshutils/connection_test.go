@@ -0,0 +1,262 @@
+package wshutils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestServer 启动 WebSocket 测试服务端，每个连接交给 handle 处理，返回 ws:// 地址
+func newTestServer(t *testing.T, handle func(c *websocket.Conn)) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		handle(c)
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// discardMessages 读取并丢弃客户端的消息直到连接断开
+func discardMessages(c *websocket.Conn) {
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// waitForGoroutines 等待协程数回到 baseline，超过 deadline 时返回当前的协程数
+func waitForGoroutines(baseline int, deadline time.Duration) (int, bool) {
+	end := time.Now().Add(deadline)
+	for {
+		n := runtime.NumGoroutine()
+		if n <= baseline {
+			return n, true
+		}
+		if time.Now().After(end) {
+			return n, false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCloseStopsBackgroundGoroutines(t *testing.T) {
+	url := newTestServer(t, discardMessages)
+	baseline := runtime.NumGoroutine()
+
+	conn, err := NewConnectionWithOptions(url, ConnectionOptions{Quiet: true})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	StartHeartbeat(conn, 5*time.Millisecond)
+	conn.StartPingHeartbeat(5 * time.Millisecond)
+	conn.StartLatencyProbe(5 * time.Millisecond)
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// 让心跳和 ping 至少发送几次
+	time.Sleep(30 * time.Millisecond)
+	conn.Close()
+
+	select {
+	case <-conn.Done():
+	default:
+		t.Fatal("Done() is not closed after Close")
+	}
+	select {
+	case <-readerDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("reader goroutine still blocked after Close")
+	}
+	if n, ok := waitForGoroutines(baseline, 2*time.Second); !ok {
+		t.Errorf("%d goroutines still running after Close, baseline %d", n, baseline)
+	}
+}
+
+func TestCloseGracefullyClosesDone(t *testing.T) {
+	url := newTestServer(t, discardMessages)
+	baseline := runtime.NumGoroutine()
+
+	conn, err := NewConnectionWithOptions(url, ConnectionOptions{Quiet: true})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	StartHeartbeat(conn, 5*time.Millisecond)
+	conn.StartLatencyProbe(5 * time.Millisecond)
+	conn.CloseGracefully()
+
+	select {
+	case <-conn.Done():
+	default:
+		t.Fatal("Done() is not closed after CloseGracefully")
+	}
+	if n, ok := waitForGoroutines(baseline, 2*time.Second); !ok {
+		t.Errorf("%d goroutines still running after CloseGracefully, baseline %d", n, baseline)
+	}
+}
+
+func TestStartPingingIgnoresNonPositiveInterval(t *testing.T) {
+	url := newTestServer(t, discardMessages)
+	conn, err := NewConnectionWithOptions(url, ConnectionOptions{Quiet: true})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer conn.Close()
+	baseline := runtime.NumGoroutine()
+
+	// time.NewTicker 遇到非正数的间隔会 panic，这里不应该启动 ping 协程
+	conn.StartPingHeartbeat(0)
+	conn.StartLatencyProbe(-time.Second)
+	if n := runtime.NumGoroutine(); n != baseline {
+		t.Errorf("%d goroutines started for non-positive intervals, want 0", n-baseline)
+	}
+}
+
+func TestPingHeartbeatAndLatencyProbeSharePingLoop(t *testing.T) {
+	pinged := make(chan struct{}, 1)
+	url := newTestServer(t, func(c *websocket.Conn) {
+		c.SetPingHandler(func(appData string) error {
+			select {
+			case pinged <- struct{}{}:
+			default:
+			}
+			return c.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(time.Second))
+		})
+		discardMessages(c)
+	})
+
+	conn, err := NewConnectionWithOptions(url, ConnectionOptions{Quiet: true})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer conn.Close()
+	baseline := runtime.NumGoroutine()
+
+	// 心跳间隔很长，延迟测量间隔很短，共用的协程应按较短的间隔发送
+	conn.StartPingHeartbeat(time.Hour)
+	conn.StartLatencyProbe(10 * time.Millisecond)
+	if n := runtime.NumGoroutine(); n != baseline+1 {
+		t.Errorf("%d goroutines started for ping heartbeat and latency probe, want 1", n-baseline)
+	}
+	select {
+	case <-pinged:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no ping sent at the latency probe interval")
+	}
+}
+
+func TestConcurrentSendJSON(t *testing.T) {
+	const perSender = 200
+	received := make(chan map[string]int, 1)
+	url := newTestServer(t, func(c *websocket.Conn) {
+		counts := map[string]int{}
+		defer func() { received <- counts }()
+		for {
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			// 并发写入交错时帧会损坏，服务端读到无效的 JSON 或直接断开
+			var msg ControlMsg
+			if err := json.Unmarshal(data, &msg); err != nil {
+				counts["invalid"]++
+				continue
+			}
+			counts[msg.Type]++
+		}
+	})
+
+	conn, err := NewConnectionWithOptions(url, ConnectionOptions{Quiet: true, TermSize: TermSize{Rows: 24, Cols: 80}})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	// 同时模拟心跳、窗口大小变化和用户输入三个发送方
+	senders := map[string]func() error{
+		"heartbeat": func() error { return conn.SendJSON(HeartbeatMsg{Type: "heartbeat", Data: ""}) },
+		"resize":    conn.ResizeTerm,
+		"cmd":       func() error { return conn.SendJSON(CmdMsg{Type: "cmd", Cmd: strings.Repeat("x", 1000)}) },
+	}
+	var wg sync.WaitGroup
+	for name, send := range senders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perSender; i++ {
+				if err := send(); err != nil {
+					t.Errorf("%s: send %d failed: %v", name, i+1, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	conn.CloseGracefully()
+
+	select {
+	case counts := <-received:
+		for name := range senders {
+			if counts[name] != perSender {
+				t.Errorf("server received %d %s messages, want %d", counts[name], name, perSender)
+			}
+		}
+		if counts["invalid"] > 0 {
+			t.Errorf("server received %d corrupted messages", counts["invalid"])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not see the connection close")
+	}
+
+	// 在终端中运行或设置了 COLUMNS/LINES 时 ResizeTerm 使用实际大小
+	size, ok := TerminalSize()
+	if !ok {
+		size = TermSize{Rows: 24, Cols: 80}
+	}
+	wantBytes := int64(0)
+	for _, v := range []any{HeartbeatMsg{Type: "heartbeat"}, CmdMsg{Type: "cmd", Cmd: strings.Repeat("x", 1000)}, ResizeMsg{Type: "resize", Rows: size.Rows, Cols: size.Cols}} {
+		data, _ := json.Marshal(v)
+		wantBytes += int64(len(data)) * perSender
+	}
+	if sent := conn.Stats().BytesSent; sent != wantBytes {
+		t.Errorf("Stats().BytesSent = %d, want %d", sent, wantBytes)
+	}
+}
+
+func TestReconnectOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ReconnectOptions
+		ok   bool
+	}{
+		{"defaults", ReconnectOptions{BaseDelay: time.Second, MaxDelay: 30 * time.Second, MaxRetries: 10}, true},
+		{"unlimited retries", ReconnectOptions{BaseDelay: time.Second, MaxDelay: time.Second}, true},
+		{"zero base delay", ReconnectOptions{MaxDelay: time.Second}, false},
+		{"max below base", ReconnectOptions{BaseDelay: 10 * time.Second, MaxDelay: time.Second}, false},
+		{"negative retries", ReconnectOptions{BaseDelay: time.Second, MaxDelay: time.Second, MaxRetries: -1}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.opts.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v", tt.name, err)
+		}
+	}
+}
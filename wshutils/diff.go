@@ -0,0 +1,109 @@
+package wshutils
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldChange 端点某个字段的变化
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// EndpointChange 同名端点的字段变化
+type EndpointChange struct {
+	Name   string        `json:"name"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// ConfigDiff 两个配置文件端点集合的差异
+type ConfigDiff struct {
+	Added   []Endpoint       `json:"added"`
+	Removed []Endpoint       `json:"removed"`
+	Changed []EndpointChange `json:"changed"`
+}
+
+// Empty 两个配置的端点是否完全一致
+func (d ConfigDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffConfigs 按端点名称比较两个配置，结果按名称排序，仅调整顺序不算变化
+func DiffConfigs(oldConfig, newConfig *Config) ConfigDiff {
+	oldEndpoints := endpointsByName(oldConfig)
+	newEndpoints := endpointsByName(newConfig)
+
+	diff := ConfigDiff{
+		Added:   []Endpoint{},
+		Removed: []Endpoint{},
+		Changed: []EndpointChange{},
+	}
+	for _, name := range sortedNames(newEndpoints) {
+		if _, ok := oldEndpoints[name]; !ok {
+			diff.Added = append(diff.Added, newEndpoints[name])
+		}
+	}
+	for _, name := range sortedNames(oldEndpoints) {
+		newEndpoint, ok := newEndpoints[name]
+		if !ok {
+			diff.Removed = append(diff.Removed, oldEndpoints[name])
+			continue
+		}
+		if fields := diffEndpoint(oldEndpoints[name], newEndpoint); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, EndpointChange{Name: name, Fields: fields})
+		}
+	}
+
+	return diff
+}
+
+// diffEndpoint 逐字段比较端点，字段名使用 yaml 标签
+func diffEndpoint(oldEndpoint, newEndpoint Endpoint) []FieldChange {
+	var changes []FieldChange
+	oldValue := reflect.ValueOf(oldEndpoint)
+	newValue := reflect.ValueOf(newEndpoint)
+	endpointType := oldValue.Type()
+
+	for i := 0; i < endpointType.NumField(); i++ {
+		field := endpointType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		a, b := oldValue.Field(i).Interface(), newValue.Field(i).Interface()
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		changes = append(changes, FieldChange{Field: name, Old: a, New: b})
+	}
+
+	return changes
+}
+
+// endpointsByName 以名称为键索引端点，重名时后者覆盖前者
+func endpointsByName(config *Config) map[string]Endpoint {
+	endpoints := make(map[string]Endpoint)
+	if config == nil {
+		return endpoints
+	}
+	for _, endpoint := range config.Endpoints {
+		endpoints[endpoint.Name] = endpoint
+	}
+	return endpoints
+}
+
+// sortedNames 返回排序后的端点名称
+func sortedNames(endpoints map[string]Endpoint) []string {
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,11 @@
+//go:build linux
+
+package wshutils
+
+import "os"
+
+// SetProcTitle 设置进程名，ps/top 中显示为该名称（内核限制为15字节，超出部分被截断）
+func SetProcTitle(title string) error {
+	// 写 /proc/self/comm 修改的是主线程的名称，不受当前 goroutine 所在线程影响
+	return os.WriteFile("/proc/self/comm", []byte(title), 0)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package wshutils
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// SetProcTitle 当前平台不支持修改进程名
+func SetProcTitle(title string) error {
+	return fmt.Errorf("setting the process title is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,80 @@
+package wshutils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// parseProxyURL 解析代理地址，支持 http:// 和 socks5://（由 gorilla/websocket 负责 CONNECT 和 SOCKS5 握手）
+func parseProxyURL(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL '%s': %v", proxy, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "socks5" {
+		return nil, fmt.Errorf("unsupported proxy scheme '%s', expected http or socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL '%s': missing host", proxy)
+	}
+	return u, nil
+}
+
+// proxyFromEnvironment 依次使用 HTTP_PROXY/HTTPS_PROXY 和 ALL_PROXY，遵守 NO_PROXY
+// 与 http.ProxyFromEnvironment 一致，本机地址不经过代理
+func proxyFromEnvironment(req *http.Request) (*url.URL, error) {
+	host := req.URL.Hostname()
+	if host == "localhost" || noProxy(host) {
+		return nil, nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil, nil
+	}
+	proxyURL, err := http.ProxyFromEnvironment(req)
+	if err != nil || proxyURL != nil {
+		return proxyURL, err
+	}
+
+	allProxy := getEnvAny("ALL_PROXY", "all_proxy")
+	if allProxy == "" {
+		return nil, nil
+	}
+	return parseProxyURL(allProxy)
+}
+
+// noProxy 判断主机是否命中 NO_PROXY（逗号分隔，支持 "*"、域名后缀和IP）
+func noProxy(host string) bool {
+	for _, entry := range strings.Split(getEnvAny("NO_PROXY", "no_proxy"), ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		// 去掉端口，NO_PROXY 按主机匹配
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		entry = strings.TrimPrefix(entry, ".")
+		host = strings.ToLower(host)
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// getEnvAny 返回第一个非空的环境变量
+func getEnvAny(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
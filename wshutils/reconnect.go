@@ -0,0 +1,105 @@
+package wshutils
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// ReconnectOptions 断线重连选项
+type ReconnectOptions struct {
+	// BaseDelay 第一次重连前的等待时间，之后每次翻倍
+	BaseDelay time.Duration
+	// MaxDelay 两次重连之间的最长等待时间
+	MaxDelay time.Duration
+	// MaxRetries 最多重连次数，0 表示不限次数
+	MaxRetries int
+}
+
+// Validate 检查重连选项，等待时间为0时会不停地重新拨号
+func (opts ReconnectOptions) Validate() error {
+	if opts.BaseDelay <= 0 {
+		return fmt.Errorf("base delay must be positive, got %v", opts.BaseDelay)
+	}
+	if opts.MaxDelay < opts.BaseDelay {
+		return fmt.Errorf("max delay %v is shorter than base delay %v", opts.MaxDelay, opts.BaseDelay)
+	}
+	if opts.MaxRetries < 0 {
+		return fmt.Errorf("max retries must be 0 (unlimited) or more, got %d", opts.MaxRetries)
+	}
+	return nil
+}
+
+// Reconnect 按指数退避重新拨号，成功后替换底层连接；连接已被主动关闭时不重连
+// 配置了多个地址时每次尝试从断开的地址开始依次拨号
+func (conn *Connection) Reconnect(opts ReconnectOptions) error {
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("invalid reconnect options: %v", err)
+	}
+	delay := opts.BaseDelay
+	for attempt := 1; opts.MaxRetries == 0 || attempt <= opts.MaxRetries; attempt++ {
+		if conn.IsClosed() {
+			return fmt.Errorf("connection closed, not reconnecting")
+		}
+
+		logrus.Warnf("Reconnecting to %s in %v (attempt %d)", conn.targetURL, delay, attempt)
+		time.Sleep(delay)
+
+		targetURL, c, extensions, pending, err := conn.connectAny()
+		if err == nil {
+			conn.installPongHandler(c)
+			conn.connMutex.Lock()
+			old := conn.conn
+			conn.conn = c
+			conn.extensions = extensions
+			conn.pending = pending
+			conn.targetURL = targetURL
+			conn.connMutex.Unlock()
+			conn.sawMessage.Store(false)
+			old.Close()
+
+			// 拨号期间连接被主动关闭，放弃新连接
+			if conn.IsClosed() {
+				c.Close()
+				return fmt.Errorf("connection closed, not reconnecting")
+			}
+
+			logrus.Infof("Reconnected to %s after %d attempt(s)", conn.targetURL, attempt)
+			return nil
+		}
+		logrus.WithError(err).Warnf("Reconnect attempt %d failed", attempt)
+
+		delay *= 2
+		if delay > opts.MaxDelay {
+			delay = opts.MaxDelay
+		}
+	}
+
+	return fmt.Errorf("giving up after %d reconnect attempts", opts.MaxRetries)
+}
+
+// connectAny 从当前地址开始依次尝试所有候选地址，返回连接成功的地址
+func (conn *Connection) connectAny() (string, *websocket.Conn, string, []byte, error) {
+	start := slices.Index(conn.urls, conn.targetURL)
+	if start < 0 {
+		start = 0
+	}
+
+	var err error
+	for i := range conn.urls {
+		targetURL := conn.urls[(start+i)%len(conn.urls)]
+		var c *websocket.Conn
+		var extensions string
+		var pending []byte
+		if c, extensions, pending, err = connect(targetURL, conn.opts); err == nil {
+			return targetURL, c, extensions, pending, nil
+		}
+		if len(conn.urls) > 1 {
+			logrus.WithError(err).Warnf("Failed to connect to %s", targetURL)
+		}
+	}
+	return "", nil, "", nil, err
+}
@@ -0,0 +1,94 @@
+package wshutils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultRelayTimeout 默认等待中继确认的时间
+const DefaultRelayTimeout = 10 * time.Second
+
+// Relay 跳板中继：端点 URL 指向中继，连接后先发送目标主机，中继回复确认行后开始转发会话
+type Relay struct {
+	// Target 由中继连接的目标主机，支持 ${VAR} 环境变量展开
+	Target string `yaml:"target" json:"target"`
+	// Type 发送给中继的消息类型，默认为 connect
+	Type string `yaml:"type" json:"type"`
+	// Ack 中继确认行中必须包含的文本，为空时收到任意一行即视为确认
+	Ack string `yaml:"ack" json:"ack"`
+	// Timeout 等待确认的秒数，0 使用 DefaultRelayTimeout
+	Timeout int `yaml:"timeout" json:"timeout"`
+}
+
+// RelayMsg 发送给中继的连接请求，如 {"type":"connect","target":"db01:8080"}
+type RelayMsg struct {
+	Type   string `json:"type"`
+	Target string `json:"target"`
+}
+
+// timeout 等待确认的时间
+func (relay *Relay) timeout() time.Duration {
+	if relay.Timeout > 0 {
+		return time.Duration(relay.Timeout) * time.Second
+	}
+	return DefaultRelayTimeout
+}
+
+// message 发送给中继的连接请求
+func (relay *Relay) message() RelayMsg {
+	msgType := relay.Type
+	if msgType == "" {
+		msgType = "connect"
+	}
+	return RelayMsg{Type: msgType, Target: relay.Target}
+}
+
+// openRelay 配置了中继时完成中继握手，失败时关闭连接
+func openRelay(c *websocket.Conn, relay *Relay) ([]byte, error) {
+	if relay == nil {
+		return nil, nil
+	}
+	pending, err := relayHandshake(c, relay)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return pending, nil
+}
+
+// relayHandshake 在刚建立的连接上请求中继连接目标主机，等待确认行
+// 确认行之后同一条消息中剩余的数据已经属于会话，作为返回值交给调用方
+func relayHandshake(c *websocket.Conn, relay *Relay) ([]byte, error) {
+	deadline := time.Now().Add(relay.timeout())
+	c.SetWriteDeadline(deadline)
+	if err := c.WriteJSON(relay.message()); err != nil {
+		return nil, fmt.Errorf("failed to send relay request: %v", err)
+	}
+	c.SetWriteDeadline(time.Time{})
+
+	c.SetReadDeadline(deadline)
+	defer c.SetReadDeadline(time.Time{})
+
+	var received []byte
+	for {
+		_, msg, err := c.ReadMessage()
+		if err != nil {
+			return nil, fmt.Errorf("relay did not acknowledge target '%s': %v", relay.Target, err)
+		}
+		received = append(received, msg...)
+
+		i := bytes.IndexByte(received, '\n')
+		if i < 0 {
+			continue
+		}
+		line := strings.TrimSpace(string(received[:i]))
+		if relay.Ack != "" && !strings.Contains(line, relay.Ack) {
+			return nil, fmt.Errorf("relay refused target '%s': %s", relay.Target, line)
+		}
+		return received[i+1:], nil
+	}
+}
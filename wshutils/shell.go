@@ -0,0 +1,8 @@
+package wshutils
+
+import "strings"
+
+// ShellQuote 用单引号包裹字符串，内部的单引号先结束引号再转义，结果可以安全地拼接到远端 shell 命令中
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
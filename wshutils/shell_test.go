@@ -0,0 +1,47 @@
+package wshutils
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"plain", "config.yaml"},
+		{"spaces", "my file  name.txt"},
+		{"single quote", "it's"},
+		{"only single quotes", "'''"},
+		{"double quotes", `say "hi"`},
+		{"dollar", "$HOME ${PATH} $(id)"},
+		{"backticks", "`id`"},
+		{"semicolon", "a; rm -rf /tmp/x"},
+		{"newline", "line1\nline2\n"},
+		{"glob", "*.go ?"},
+		{"redirect and pipe", "a > b | c & d"},
+		{"backslash", `back\slash\'`},
+		{"leading dash", "-rf"},
+		{"tilde", "~/file"},
+		{"utf-8", "文件 名"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// sh 解析引号后原样输出，与远端 shell 看到的参数相同
+			out, err := exec.Command(sh, "-c", "printf %s "+ShellQuote(tt.value)).Output()
+			if err != nil {
+				t.Fatalf("sh failed for %q: %v", tt.value, err)
+			}
+			if string(out) != tt.value {
+				t.Errorf("ShellQuote(%q) round-tripped to %q", tt.value, out)
+			}
+		})
+	}
+}
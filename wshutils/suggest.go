@@ -0,0 +1,79 @@
+package wshutils
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions 最多给出的候选端点数量
+const maxSuggestions = 3
+
+// SuggestEndpoints 返回与 name 编辑距离足够近的端点名称，按距离从近到远排序
+func SuggestEndpoints(config *Config, name string) []string {
+	// 名称越长允许的拼写错误越多，至少允许1个字符的差异
+	threshold := len(name) / 3
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	for _, endpoint := range config.Endpoints {
+		distance := levenshtein(strings.ToLower(name), strings.ToLower(endpoint.Name))
+		if distance <= threshold {
+			candidates = append(candidates, candidate{name: endpoint.Name, distance: distance})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	var names []string
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		names = append(names, candidates[i].name)
+	}
+	return names
+}
+
+// levenshtein 计算两个字符串的编辑距离（按 rune 计算）
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// FormatSuggestions 生成 "did you mean" 提示，没有候选时返回空字符串
+func FormatSuggestions(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		quoted[i] = "'" + s + "'"
+	}
+	return "Did you mean " + strings.Join(quoted, " or ") + "?"
+}
@@ -0,0 +1,56 @@
+package wshutils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// TermSize 终端的列数和行数
+type TermSize struct {
+	Cols int
+	Rows int
+}
+
+// DefaultTermSize 无法从终端、环境变量和 ConnectionOptions.TermSize 确定大小时发送的大小
+var DefaultTermSize = TermSize{Cols: 196, Rows: 47}
+
+// IsZero 大小未设置
+func (s TermSize) IsZero() bool {
+	return s.Cols == 0 && s.Rows == 0
+}
+
+// String 按 "列x行" 格式输出
+func (s TermSize) String() string {
+	return fmt.Sprintf("%dx%d", s.Cols, s.Rows)
+}
+
+// ParseTermSize 解析 "196x47" 格式（列x行）的终端大小
+func ParseTermSize(s string) (TermSize, error) {
+	colsText, rowsText, ok := strings.Cut(strings.ToLower(strings.TrimSpace(s)), "x")
+	if ok {
+		cols, colsErr := strconv.Atoi(colsText)
+		rows, rowsErr := strconv.Atoi(rowsText)
+		if colsErr == nil && rowsErr == nil && cols > 0 && rows > 0 {
+			return TermSize{Cols: cols, Rows: rows}, nil
+		}
+	}
+	return TermSize{}, fmt.Errorf("invalid terminal size '%s', expected COLSxROWS like 120x40", s)
+}
+
+// TerminalSize 依次从 stdout 终端和 COLUMNS/LINES 环境变量获取终端大小，都无法确定时 ok 为 false
+func TerminalSize() (size TermSize, ok bool) {
+	if cols, rows, err := term.GetSize(int(os.Stdout.Fd())); err == nil && cols > 0 && rows > 0 {
+		return TermSize{Cols: cols, Rows: rows}, true
+	}
+
+	cols, colsErr := strconv.Atoi(os.Getenv("COLUMNS"))
+	rows, rowsErr := strconv.Atoi(os.Getenv("LINES"))
+	if colsErr == nil && rowsErr == nil && cols > 0 && rows > 0 {
+		return TermSize{Cols: cols, Rows: rows}, true
+	}
+	return TermSize{}, false
+}
@@ -0,0 +1,355 @@
+package wshutils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultChunkSize 默认每条消息携带的编码数据字节数
+	DefaultChunkSize = 256
+	// MinChunkSize 和 MaxChunkSize 为数据块大小的允许范围；远端 PTY 处于规范模式时单行超过4095字节会被截断
+	MinChunkSize = 16
+	MaxChunkSize = 64 * 1024
+	// DefaultEOFMarker 默认 heredoc 结束标记
+	DefaultEOFMarker = "__EOF"
+	// BinaryChunkSize 二进制模式下每个帧携带的字节数
+	BinaryChunkSize = 32 * 1024
+)
+
+// eofMarkerPattern 结束标记只允许字母、数字和下划线，避免 heredoc 引号问题
+var eofMarkerPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// randomMarkerPrefix 随机结束标记的前缀，下划线不属于标准 base64 字符，编码数据中不会出现这样的行
+const randomMarkerPrefix = "__WSH_EOF_"
+
+// TransferOptions 文件传输选项，零值使用默认设置
+type TransferOptions struct {
+	// ChunkSize 每条消息携带的编码数据字节数
+	ChunkSize int
+	// EOFMarker heredoc 结束标记；可能出现在数据中时换成随机标记，见 SendHeredoc 和 SendHeredocReader
+	EOFMarker string
+	// Progress 每发送一个数据块后调用；SendFile 中为已读取的源文件字节数和文件大小，SendHeredoc 中为编码后的字节数
+	Progress func(sent, total int)
+}
+
+// withDefaults 为未设置的字段填入默认值
+func (o TransferOptions) withDefaults() TransferOptions {
+	if o.ChunkSize == 0 {
+		o.ChunkSize = DefaultChunkSize
+	}
+	if o.EOFMarker == "" {
+		o.EOFMarker = DefaultEOFMarker
+	}
+	return o
+}
+
+// Validate 检查数据块大小和结束标记是否合法
+func (o TransferOptions) Validate() error {
+	o = o.withDefaults()
+	if o.ChunkSize < MinChunkSize || o.ChunkSize > MaxChunkSize {
+		return fmt.Errorf("chunk size must be between %d and %d", MinChunkSize, MaxChunkSize)
+	}
+	if !eofMarkerPattern.MatchString(o.EOFMarker) {
+		return fmt.Errorf("EOF marker may only contain letters, digits and underscores")
+	}
+	// 单个字符的标记无法在数据块中拆开，也太容易与数据相同
+	if len(o.EOFMarker) < 2 {
+		return fmt.Errorf("EOF marker must be at least 2 characters")
+	}
+	return nil
+}
+
+// SendFile 将本地文件以 gzip+base64 编码通过 heredoc 发送，远端解码后写入 remoteName
+func (conn *Connection) SendFile(localPath string, remoteName string, opts TransferOptions) error {
+	return SendFile(conn, localPath, remoteName, opts)
+}
+
+// SendHeredoc 发送 cat <<'MARKER' |pipeline 握手、编码数据和结束标记
+func (conn *Connection) SendHeredoc(pipeline string, encodedData string, opts TransferOptions) error {
+	return SendHeredoc(conn, pipeline, encodedData, opts)
+}
+
+// SendFileBinary 以二进制帧发送文件原始内容，不做压缩和 base64 编码，远端用 head -c 按长度接收
+func (conn *Connection) SendFileBinary(localPath string, remoteName string, opts TransferOptions) error {
+	return SendFileBinary(conn, localPath, remoteName, opts)
+}
+
+// SendFileBinary 通过任意 ConnectionInterface 以二进制帧发送文件
+// 需要服务端把二进制帧原样写入 shell 的标准输入；传输期间用 stty raw 避免终端转换换行和控制字符
+func SendFileBinary(c ConnectionInterface, localPath string, remoteName string, opts TransferOptions) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %v", err)
+	}
+	size := int(info.Size())
+
+	handshakeMsg := fmt.Sprintf("stty raw; head -c %d > %s; stty -raw\n", size, ShellQuote(remoteName))
+	if err := c.SendJSON(CmdMsg{Type: "cmd", Cmd: handshakeMsg}); err != nil {
+		return fmt.Errorf("failed to send handshake: %v", err)
+	}
+
+	buf := make([]byte, BinaryChunkSize)
+	for sent := 0; sent < size; {
+		n, err := io.ReadFull(file, buf[:min(BinaryChunkSize, size-sent)])
+		if err != nil {
+			return fmt.Errorf("failed to read source file at offset %d: %v", sent, err)
+		}
+		if err := c.SendBinary(buf[:n]); err != nil {
+			return fmt.Errorf("failed to send data at offset %d: %v", sent, err)
+		}
+		sent += n
+		if opts.Progress != nil {
+			opts.Progress(sent, size)
+		}
+	}
+
+	return nil
+}
+
+// SendFile 通过任意 ConnectionInterface 发送本地文件，远端解码后写入 remoteName
+// 编码与发送同时进行，不会把整个文件读入内存；Progress 报告已读取的源文件字节数和文件大小
+func SendFile(c ConnectionInterface, localPath string, remoteName string, opts TransferOptions) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %v", err)
+	}
+
+	source := &countingReader{r: file}
+	encoded := EncodeStream(source)
+	defer encoded.Close()
+
+	if progress := opts.Progress; progress != nil {
+		size := int(info.Size())
+		opts.Progress = func(int, int) { progress(int(source.n.Load()), size) }
+	}
+	return SendHeredocReader(c, "base64 --decode |gunzip > "+ShellQuote(remoteName), encoded, opts)
+}
+
+// SendHeredoc 通过任意 ConnectionInterface 发送 heredoc 握手、编码数据和结束标记
+// 发送前检查结束标记是否与某一行数据相同，相同时换成随机标记
+func SendHeredoc(c ConnectionInterface, pipeline string, encodedData string, opts TransferOptions) error {
+	opts = opts.withDefaults()
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	for markerInPayload(opts.EOFMarker, encodedData, opts.ChunkSize) {
+		marker, err := randomEOFMarker()
+		if err != nil {
+			return err
+		}
+		logrus.Warnf("EOF marker '%s' appears in the encoded data, using '%s' instead", opts.EOFMarker, marker)
+		opts.EOFMarker = marker
+	}
+
+	if progress := opts.Progress; progress != nil {
+		opts.Progress = func(sent, _ int) { progress(sent, len(encodedData)) }
+	}
+	return sendHeredoc(c, pipeline, strings.NewReader(encodedData), opts)
+}
+
+// SendHeredocReader 边读边发送 r 中已编码的 base64 数据，每 ChunkSize 字节一条消息，总长度未知，Progress 的 total 为 0
+// 数据无法在握手前检查，标记只由 base64 字符组成且不长于数据块时可能与某个数据块相同，这时换成随机标记
+func SendHeredocReader(c ConnectionInterface, pipeline string, r io.Reader, opts TransferOptions) error {
+	opts = opts.withDefaults()
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if markerMayCollide(opts.EOFMarker, opts.ChunkSize) {
+		marker, err := randomEOFMarker()
+		if err != nil {
+			return err
+		}
+		logrus.Warnf("EOF marker '%s' may appear in the encoded data, using '%s' instead", opts.EOFMarker, marker)
+		opts.EOFMarker = marker
+	}
+	return sendHeredoc(c, pipeline, r, opts)
+}
+
+// markerInPayload 检查按 size 分块发送后远端看到的各行中是否有与标记相同的行
+func markerInPayload(marker string, encodedData string, size int) bool {
+	for _, chunk := range SplitChunks(encodedData, size) {
+		for _, line := range strings.Split(chunk, "\n") {
+			if line == marker {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// markerMayCollide 标记中含有 base64 以外的字符（如下划线）或比数据块长时，不会与 base64 数据块相同
+func markerMayCollide(marker string, size int) bool {
+	return len(marker) <= size && !strings.Contains(marker, "_")
+}
+
+// randomEOFMarker 生成不会出现在 base64 数据中的随机结束标记
+func randomEOFMarker() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate EOF marker: %v", err)
+	}
+	return randomMarkerPrefix + hex.EncodeToString(buf), nil
+}
+
+// sendHeredoc 发送握手、数据块和结束标记，opts 已经检查过
+// 数据不是 base64 时仍可能有数据块等于结束标记，这样的数据块拆成两行发送，远端 heredoc 不会提前结束
+func sendHeredoc(c ConnectionInterface, pipeline string, r io.Reader, opts TransferOptions) error {
+	marker := opts.EOFMarker
+
+	// 发送握手消息
+	handshakeMsg := fmt.Sprintf("cat <<'%s' |%s\n", marker, pipeline)
+	if err := c.SendJSON(CmdMsg{Type: "cmd", Cmd: handshakeMsg}); err != nil {
+		return fmt.Errorf("failed to send handshake: %v", err)
+	}
+
+	// 分块发送编码后的数据
+	buf := make([]byte, opts.ChunkSize)
+	sent := 0
+	for chunkIndex := 1; ; chunkIndex++ {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			// 结束 heredoc，避免远端 shell 一直等待输入
+			c.SendJSON(CmdMsg{Type: "cmd", Cmd: marker + "\n"})
+			return fmt.Errorf("failed to encode data: %v", readErr)
+		}
+		if n > 0 {
+			chunk := string(buf[:n])
+			if chunk == marker {
+				chunk = chunk[:n/2] + "\n" + chunk[n/2:]
+			}
+			if err := c.SendJSON(CmdMsg{Type: "cmd", Cmd: chunk + "\n"}); err != nil {
+				return fmt.Errorf("failed to send chunk %d: %v", chunkIndex, err)
+			}
+			sent += n
+			if opts.Progress != nil {
+				opts.Progress(sent, 0)
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	// 发送结束标记
+	if err := c.SendJSON(CmdMsg{Type: "cmd", Cmd: marker + "\n"}); err != nil {
+		return fmt.Errorf("failed to send end marker: %v", err)
+	}
+
+	return nil
+}
+
+// countingReader 统计已读取的字节数，可以在其他协程中读取计数
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n.Add(int64(n))
+	return n, err
+}
+
+// EncodeStream 返回 r 经 gzip 压缩和 base64 编码后的数据流，编码在后台协程中按需进行
+// 读取方关闭返回的流后，编码协程会随之退出
+func EncodeStream(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		encoder := base64.NewEncoder(base64.StdEncoding, pw)
+		gw := gzip.NewWriter(encoder)
+
+		_, err := io.Copy(gw, r)
+		if err == nil {
+			err = gw.Close()
+		}
+		if err == nil {
+			err = encoder.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// EncodeFile 读取文件并进行 gzip 压缩和 base64 编码
+func EncodeFile(localPath string) (string, error) {
+	sourceFile, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open source file: %v", err)
+	}
+	defer sourceFile.Close()
+
+	return EncodeReader(sourceFile)
+}
+
+// EncodeReader 对 r 的内容进行 gzip 压缩和 base64 编码
+func EncodeReader(r io.Reader) (string, error) {
+	var base64Buffer bytes.Buffer
+	encoder := base64.NewEncoder(base64.StdEncoding, &base64Buffer)
+	gw := gzip.NewWriter(encoder)
+
+	if _, err := io.Copy(gw, r); err != nil {
+		return "", fmt.Errorf("failed to compress data: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		return "", fmt.Errorf("failed to close gzip writer: %v", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("failed to close base64 encoder: %v", err)
+	}
+
+	return base64Buffer.String(), nil
+}
+
+// SplitChunks 将编码后的数据按 size 切分（最后一块可以不足 size）
+func SplitChunks(encodedData string, size int) []string {
+	totalChunks := (len(encodedData) + size - 1) / size
+	chunks := make([]string, 0, totalChunks)
+
+	for i := 0; i < totalChunks; i++ {
+		start := i * size
+		end := start + size
+		if end > len(encodedData) {
+			end = len(encodedData)
+		}
+		chunks = append(chunks, encodedData[start:end])
+	}
+
+	return chunks
+}
+
+// DecodeStream 解码 base64+gzip 数据流，base64 解码时忽略换行
+func DecodeStream(r io.Reader, w io.Writer) error {
+	gr, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, r))
+	if err != nil {
+		return fmt.Errorf("failed to open gzip stream: %v", err)
+	}
+	defer gr.Close()
+
+	if _, err := io.Copy(w, gr); err != nil {
+		return fmt.Errorf("failed to decompress stream: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,265 @@
+package wshutils_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/gitchs/wsh/wshutils/wshtest"
+)
+
+// decodeCommands 去掉握手和结束标记，把数据行按远端 base64 --decode |gunzip 的方式还原
+func decodeCommands(t *testing.T, commands []string) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	if err := wshutils.DecodeStream(strings.NewReader(strings.Join(commands[1:len(commands)-1], "")), &out); err != nil {
+		t.Fatalf("DecodeStream: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestSendFile(t *testing.T) {
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(data)
+	localPath := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(localPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conn := wshtest.NewFakeConnection()
+	opts := wshutils.TransferOptions{ChunkSize: 64}
+	if err := wshutils.SendFile(conn, localPath, "dir with space/it's.bin", opts); err != nil {
+		t.Fatalf("SendFile: %v", err)
+	}
+
+	commands := conn.Commands()
+	if len(commands) < 3 {
+		t.Fatalf("got %d commands, want handshake, data and end marker", len(commands))
+	}
+	wantHandshake := `cat <<'__EOF' |base64 --decode |gunzip > 'dir with space/it'\''s.bin'` + "\n"
+	if commands[0] != wantHandshake {
+		t.Errorf("handshake = %q, want %q", commands[0], wantHandshake)
+	}
+	if last := commands[len(commands)-1]; last != "__EOF\n" {
+		t.Errorf("end marker = %q, want %q", last, "__EOF\n")
+	}
+	// 每个数据块一行，除最后一块外都正好是 ChunkSize 字节
+	chunks := commands[1 : len(commands)-1]
+	for i, chunk := range chunks {
+		line, ok := strings.CutSuffix(chunk, "\n")
+		if !ok || strings.Contains(line, "\n") {
+			t.Fatalf("chunk %d = %q, want a single line", i+1, chunk)
+		}
+		if i < len(chunks)-1 && len(line) != opts.ChunkSize {
+			t.Errorf("chunk %d has %d bytes, want %d", i+1, len(line), opts.ChunkSize)
+		}
+	}
+	if got := decodeCommands(t, commands); !bytes.Equal(got, data) {
+		t.Errorf("decoded %d bytes, not identical to the %d source bytes", len(got), len(data))
+	}
+}
+
+func TestSendFileMissingSource(t *testing.T) {
+	conn := wshtest.NewFakeConnection()
+	if err := wshutils.SendFile(conn, filepath.Join(t.TempDir(), "missing"), "out", wshutils.TransferOptions{}); err == nil {
+		t.Fatal("SendFile succeeded for a missing file")
+	}
+	if sent := conn.Sent(); len(sent) != 0 {
+		t.Errorf("sent %d messages before failing, want none", len(sent))
+	}
+}
+
+func TestSendHeredocReaderMarkerCollision(t *testing.T) {
+	marker := "END_OF_PAYLOAD_1"
+	opts := wshutils.TransferOptions{ChunkSize: len(marker), EOFMarker: marker}
+	payload := strings.Repeat("A", len(marker)) + marker + "tail"
+
+	conn := wshtest.NewFakeConnection()
+	if err := wshutils.SendHeredocReader(conn, "cat > out", strings.NewReader(payload), opts); err != nil {
+		t.Fatalf("SendHeredocReader: %v", err)
+	}
+
+	want := []string{
+		"cat <<'" + marker + "' |cat > out\n",
+		strings.Repeat("A", len(marker)) + "\n",
+		// 含有下划线的标记不会被替换，数据不是 base64 时与标记相同的数据块拆成两行，远端 heredoc 不会提前结束
+		marker[:len(marker)/2] + "\n" + marker[len(marker)/2:] + "\n",
+		"tail\n",
+		marker + "\n",
+	}
+	got := conn.Commands()
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("commands =\n%q\nwant\n%q", got, want)
+	}
+
+	// 远端看到的各行中只有最后一行等于标记
+	lines := strings.Split(strings.TrimSuffix(strings.Join(got[1:], ""), "\n"), "\n")
+	for i, line := range lines[:len(lines)-1] {
+		if line == marker {
+			t.Errorf("line %d equals the end marker", i+1)
+		}
+	}
+}
+
+// heredocMarker 返回握手中使用的结束标记，并检查最后一条消息是同一个标记
+func heredocMarker(t *testing.T, commands []string) string {
+	t.Helper()
+	handshake := commands[0]
+	start := strings.Index(handshake, "<<'") + 3
+	end := strings.Index(handshake[start:], "'")
+	if start < 3 || end < 0 {
+		t.Fatalf("unexpected handshake %q", handshake)
+	}
+	marker := handshake[start : start+end]
+	if last := commands[len(commands)-1]; last != marker+"\n" {
+		t.Fatalf("last command = %q, want the end marker %q from the handshake", last, marker)
+	}
+	return marker
+}
+
+func TestSendHeredocReplacesMarkerInPayload(t *testing.T) {
+	marker := "ABCDEFGHIJKLMNOP"
+	opts := wshutils.TransferOptions{ChunkSize: len(marker), EOFMarker: marker}
+	payload := strings.Repeat("Q", len(marker)) + marker + "tail"
+
+	conn := wshtest.NewFakeConnection()
+	if err := wshutils.SendHeredoc(conn, "cat > out", payload, opts); err != nil {
+		t.Fatalf("SendHeredoc: %v", err)
+	}
+	got := heredocMarker(t, conn.Commands())
+	if got == marker || !strings.HasPrefix(got, "__WSH_EOF_") {
+		t.Fatalf("marker = %q, want a random replacement", got)
+	}
+	// 换了标记后数据块原样发送，不需要拆分
+	if data := strings.Join(conn.Commands()[1:len(conn.Commands())-1], ""); data != strings.Repeat("Q", len(marker))+"\n"+marker+"\n"+"tail\n" {
+		t.Errorf("data lines = %q", data)
+	}
+
+	// 数据中没有这一行时保留给定的标记
+	conn = wshtest.NewFakeConnection()
+	if err := wshutils.SendHeredoc(conn, "cat > out", strings.Repeat("Q", 40), opts); err != nil {
+		t.Fatalf("SendHeredoc: %v", err)
+	}
+	if got := heredocMarker(t, conn.Commands()); got != marker {
+		t.Errorf("marker = %q, want %q kept", got, marker)
+	}
+}
+
+func TestSendHeredocReaderReplacesPossiblyCollidingMarker(t *testing.T) {
+	tests := []struct {
+		marker string
+		keep   bool
+	}{
+		{wshutils.DefaultEOFMarker, true},
+		// 含有下划线，不会出现在 base64 数据中
+		{"WCP_END", true},
+		// 比数据块长，不会与数据块相同
+		{strings.Repeat("Z", 17), true},
+		// 只有 base64 字符，可能等于某个数据块或最后一块
+		{"WCPEND", false},
+		{strings.Repeat("Z", 16), false},
+	}
+	for _, tt := range tests {
+		conn := wshtest.NewFakeConnection()
+		opts := wshutils.TransferOptions{ChunkSize: 16, EOFMarker: tt.marker}
+		if err := wshutils.SendHeredocReader(conn, "cat > out", strings.NewReader(strings.Repeat("Q", 40)), opts); err != nil {
+			t.Fatalf("marker %q: %v", tt.marker, err)
+		}
+		got := heredocMarker(t, conn.Commands())
+		if tt.keep && got != tt.marker {
+			t.Errorf("marker %q replaced with %q", tt.marker, got)
+		}
+		if !tt.keep && !strings.HasPrefix(got, "__WSH_EOF_") {
+			t.Errorf("marker %q kept as %q, want a random replacement", tt.marker, got)
+		}
+	}
+}
+
+// failingReader 返回一部分数据后报错
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestSendHeredocReaderReadError(t *testing.T) {
+	readErr := errors.New("disk on fire")
+	conn := wshtest.NewFakeConnection()
+	err := wshutils.SendHeredocReader(conn, "cat > out", &failingReader{data: strings.Repeat("x", 20), err: readErr}, wshutils.TransferOptions{ChunkSize: 16})
+	if err == nil || !strings.Contains(err.Error(), readErr.Error()) {
+		t.Fatalf("error = %v, want it to mention %q", err, readErr)
+	}
+	// 出错后仍然发送结束标记，远端 shell 不会一直等待输入
+	commands := conn.Commands()
+	if last := commands[len(commands)-1]; last != "__EOF\n" {
+		t.Errorf("last command = %q, want the end marker", last)
+	}
+}
+
+func TestSendHeredocReaderInvalidOptions(t *testing.T) {
+	for _, opts := range []wshutils.TransferOptions{
+		{ChunkSize: wshutils.MinChunkSize - 1},
+		{ChunkSize: wshutils.MaxChunkSize + 1},
+		{EOFMarker: "bad marker"},
+		{EOFMarker: "X"},
+	} {
+		conn := wshtest.NewFakeConnection()
+		if err := wshutils.SendHeredocReader(conn, "cat > out", strings.NewReader("data"), opts); err == nil {
+			t.Errorf("options %+v accepted", opts)
+		}
+		if sent := conn.Sent(); len(sent) != 0 {
+			t.Errorf("options %+v: sent %d messages, want none", opts, len(sent))
+		}
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	sizes := []int{0, 1, 100, wshutils.DefaultChunkSize - 1, wshutils.DefaultChunkSize, wshutils.DefaultChunkSize + 1, 4096, 100000}
+	for _, size := range sizes {
+		data := make([]byte, size)
+		rng.Read(data)
+
+		encoded, err := wshutils.EncodeReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("size %d: EncodeReader: %v", size, err)
+		}
+		// 边读边发送使用的 EncodeStream 与 EncodeReader 输出相同
+		stream := wshutils.EncodeStream(bytes.NewReader(data))
+		streamed, err := io.ReadAll(stream)
+		stream.Close()
+		if err != nil || string(streamed) != encoded {
+			t.Fatalf("size %d: EncodeStream differs from EncodeReader (err %v)", size, err)
+		}
+
+		chunks := wshutils.SplitChunks(encoded, wshutils.DefaultChunkSize)
+		for i, chunk := range chunks {
+			if len(chunk) > wshutils.DefaultChunkSize || (i < len(chunks)-1 && len(chunk) != wshutils.DefaultChunkSize) {
+				t.Fatalf("size %d: chunk %d has %d bytes", size, i+1, len(chunk))
+			}
+		}
+
+		// 与 wcp encode 的输出相同，每个数据块一行
+		var out bytes.Buffer
+		if err := wshutils.DecodeStream(strings.NewReader(strings.Join(chunks, "\n")+"\n"), &out); err != nil {
+			t.Fatalf("size %d: DecodeStream: %v", size, err)
+		}
+		if !bytes.Equal(out.Bytes(), data) {
+			t.Errorf("size %d: decoded %d bytes, not identical to the source", size, out.Len())
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package wshutils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// unixURLPrefix 通过 Unix 域套接字连接的 URL 前缀，格式为 ws+unix:///path/to/socket:/ws
+const unixURLPrefix = "ws+unix://"
+
+// splitUnixURL 将 ws+unix URL 拆分为套接字路径和握手使用的 ws:// URL
+// 套接字路径与 HTTP 路径以最后一个 ":/" 分隔，省略时 HTTP 路径为 "/"
+func splitUnixURL(targetURL string) (socketPath string, dialURL string, err error) {
+	rest := strings.TrimPrefix(targetURL, unixURLPrefix)
+	httpPath := "/"
+	if i := strings.LastIndex(rest, ":/"); i >= 0 {
+		rest, httpPath = rest[:i], rest[i+1:]
+	}
+	if rest == "" {
+		return "", "", fmt.Errorf("invalid URL '%s': missing socket path", targetURL)
+	}
+	// Host 头使用固定的 localhost，服务端只需要路径
+	return rest, "ws://localhost" + httpPath, nil
+}
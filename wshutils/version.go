@@ -0,0 +1,74 @@
+package wshutils
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// 构建信息，由 -ldflags 注入，如：
+// go build -ldflags "-X github.com/gitchs/wsh/wshutils.Version=v1.2.0 -X github.com/gitchs/wsh/wshutils.Commit=abc1234 -X github.com/gitchs/wsh/wshutils.BuildDate=2024-01-01T00:00:00Z"
+var (
+	Version   = ""
+	Commit    = ""
+	BuildDate = ""
+)
+
+// BuildInfo 版本、提交和构建时间，未通过 -ldflags 注入时从 runtime/debug.ReadBuildInfo 读取
+type BuildInfo struct {
+	Version   string
+	Commit    string
+	BuildDate string
+	// Modified 构建时工作区有未提交的修改，只能从 ReadBuildInfo 得知
+	Modified bool
+}
+
+// GetBuildInfo 返回当前程序的构建信息，无法确定的字段为 "unknown"
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{Version: Version, Commit: Commit, BuildDate: BuildDate}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		// go install 安装的版本号，源码目录中构建时为 (devel)
+		if info.Version == "" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				// 没有注入构建时间时以最后一次提交的时间代替
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if len(info.Commit) > 12 {
+		info.Commit = info.Commit[:12]
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+// String 单行版本信息，如 "v1.2.0 (commit abc1234, built 2024-01-01T00:00:00Z, go1.23.0)"
+func (info BuildInfo) String() string {
+	commit := info.Commit
+	if info.Modified {
+		commit += "-dirty"
+	}
+	return fmt.Sprintf("%s (commit %s, built %s, %s)", info.Version, commit, info.BuildDate, runtime.Version())
+}
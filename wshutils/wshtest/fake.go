@@ -0,0 +1,140 @@
+// Package wshtest 提供 wshutils.ConnectionInterface 的内存实现，用于在没有服务端的情况下测试
+package wshtest
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/gitchs/wsh/wshutils"
+	"github.com/gorilla/websocket"
+)
+
+// ErrClosed 连接关闭后收发消息返回的错误
+var ErrClosed = errors.New("fake connection closed")
+
+// message 一条待读取的消息
+type message struct {
+	messageType int
+	data        []byte
+}
+
+// FakeConnection 记录所有发送的消息，并按 Push 的顺序返回接收的消息
+type FakeConnection struct {
+	mu      sync.Mutex
+	sent    [][]byte
+	binary  [][]byte
+	closed  bool
+	inbox   chan message
+	closing chan struct{}
+	once    sync.Once
+}
+
+// 确保 *FakeConnection 实现 wshutils.ConnectionInterface
+var _ wshutils.ConnectionInterface = (*FakeConnection)(nil)
+
+// NewFakeConnection 创建内存连接
+func NewFakeConnection() *FakeConnection {
+	return &FakeConnection{
+		inbox:   make(chan message, 64),
+		closing: make(chan struct{}),
+	}
+}
+
+// SendJSON 记录 JSON 编码后的消息
+func (f *FakeConnection) SendJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return f.record(data)
+}
+
+// SendText 记录文本消息
+func (f *FakeConnection) SendText(data string) error {
+	return f.record([]byte(data))
+}
+
+// SendBinary 记录二进制消息
+func (f *FakeConnection) SendBinary(data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrClosed
+	}
+	f.binary = append(f.binary, append([]byte(nil), data...))
+	return nil
+}
+
+// record 保存一条已发送的消息
+func (f *FakeConnection) record(data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrClosed
+	}
+	f.sent = append(f.sent, data)
+	return nil
+}
+
+// ReadMessage 阻塞直到有 Push 的消息或连接关闭
+func (f *FakeConnection) ReadMessage() (int, []byte, error) {
+	select {
+	case msg := <-f.inbox:
+		return msg.messageType, msg.data, nil
+	case <-f.closing:
+		return 0, nil, ErrClosed
+	}
+}
+
+// Close 关闭连接，之后的收发都返回 ErrClosed
+func (f *FakeConnection) Close() error {
+	f.once.Do(func() {
+		f.mu.Lock()
+		f.closed = true
+		f.mu.Unlock()
+		close(f.closing)
+	})
+	return nil
+}
+
+// Done 返回 Close 后关闭的通道，wshutils.StartHeartbeat 据此停止
+func (f *FakeConnection) Done() <-chan struct{} {
+	return f.closing
+}
+
+// Push 放入一条供 ReadMessage 返回的文本消息
+func (f *FakeConnection) Push(data string) {
+	f.inbox <- message{messageType: websocket.TextMessage, data: []byte(data)}
+}
+
+// Sent 返回所有已发送文本消息的副本
+func (f *FakeConnection) Sent() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sent := make([][]byte, len(f.sent))
+	copy(sent, f.sent)
+	return sent
+}
+
+// SentBinary 返回所有已发送二进制消息的副本
+func (f *FakeConnection) SentBinary() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	binary := make([][]byte, len(f.binary))
+	copy(binary, f.binary)
+	return binary
+}
+
+// Commands 返回已发送 cmd 消息的 Cmd 字段，忽略其他类型的消息
+func (f *FakeConnection) Commands() []string {
+	var commands []string
+	for _, data := range f.Sent() {
+		var msg wshutils.CmdMsg
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Type != "cmd" {
+			continue
+		}
+		commands = append(commands, msg.Cmd)
+	}
+	return commands
+}